@@ -3,7 +3,10 @@ package cache
 import (
 	"time"
 
+	"go-trace/trace"
+
 	"github.com/go-redis/redis/v8"
+	"github.com/opentracing/opentracing-go"
 )
 
 // Config redis config
@@ -23,6 +26,9 @@ type Config struct {
 	DialTimeout  time.Duration
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	Statement    trace.Sanitizer // db.statement of spans, values are replaced by default
+	PoolStatsTag bool            // tag commands waiting for a connection with pool stats, single/sentinel mode available
+	PoolMetrics  string          // registers a PoolCollector with this name label to the default prometheus registry, empty disables
 }
 
 // New return redis connect.
@@ -42,6 +48,104 @@ func New(c *Config) *redis.Client {
 		MaxRetries:   c.MaxRetries,
 		MinIdleConns: c.MinIdleConns,
 	})
-	conn.AddHook(NewTracingHook())
+	conn.AddHook(newTracingHook(c, conn))
+	conn.AddHook(newPeerHook(c.Addr))
+	registerPool(c.PoolMetrics, conn)
+	return conn
+}
+
+// NewCluster return redis cluster connect, c.Addrs are the seed nodes.
+// Spans are tagged with the node address and the cluster slot of the key.
+func NewCluster(c *Config) *redis.ClusterClient {
+	conn := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:        c.Addrs,
+		Username:     c.Username,
+		Password:     c.Password,
+		IdleTimeout:  time.Duration(c.IdleTimeout),
+		DialTimeout:  time.Duration(c.DialTimeout),
+		ReadTimeout:  time.Duration(c.ReadTimeout),
+		WriteTimeout: time.Duration(c.WriteTimeout),
+		PoolSize:     c.PoolSize,
+		PoolTimeout:  time.Duration(c.PoolTimeout),
+		MaxRetries:   c.MaxRetries,
+		MinIdleConns: c.MinIdleConns,
+		NewClient: func(opt *redis.Options) *redis.Client {
+			node := redis.NewClient(opt)
+			node.AddHook(newPeerHook(opt.Addr))
+			return node
+		},
+	})
+	conn.AddHook(&TracingHook{Statement: c.Statement})
+	conn.AddHook(slotHook{})
+	registerPool(c.PoolMetrics, conn)
 	return conn
 }
+
+// NewFailover return redis connect to the master c.MasterName by sentinel, c.Addrs are sentinel addresses.
+// Spans are tagged with the master name and the current master address.
+func NewFailover(c *Config) *redis.Client {
+	master := newMasterAddr(c)
+	conn := redis.NewFailoverClient(&redis.FailoverOptions{
+		MasterName:    c.MasterName,
+		SentinelAddrs: c.Addrs,
+		Username:      c.Username,
+		Password:      c.Password,
+		DB:            c.DB,
+		IdleTimeout:   time.Duration(c.IdleTimeout),
+		DialTimeout:   time.Duration(c.DialTimeout),
+		ReadTimeout:   time.Duration(c.ReadTimeout),
+		WriteTimeout:  time.Duration(c.WriteTimeout),
+		PoolSize:      c.PoolSize,
+		PoolTimeout:   time.Duration(c.PoolTimeout),
+		MaxRetries:    c.MaxRetries,
+		MinIdleConns:  c.MinIdleConns,
+		Dialer:        master.dialer(),
+	})
+	conn.AddHook(newTracingHook(c, conn))
+	conn.AddHook(peerHook{
+		addr: master.Addr,
+		tags: []opentracing.Tag{{Key: tagRedisMasterName, Value: c.MasterName}},
+	})
+	registerPool(c.PoolMetrics, conn)
+	return conn
+}
+
+// NewRing return redis ring connect, keys are sharded over c.Addrs by consistent hashing.
+// Spans are tagged with the shard address.
+func NewRing(c *Config) *redis.Ring {
+	addrs := make(map[string]string, len(c.Addrs))
+	for _, addr := range c.Addrs {
+		addrs[addr] = addr
+	}
+	conn := redis.NewRing(&redis.RingOptions{
+		Addrs:        addrs,
+		Username:     c.Username,
+		Password:     c.Password,
+		DB:           c.DB,
+		IdleTimeout:  time.Duration(c.IdleTimeout),
+		DialTimeout:  time.Duration(c.DialTimeout),
+		ReadTimeout:  time.Duration(c.ReadTimeout),
+		WriteTimeout: time.Duration(c.WriteTimeout),
+		PoolSize:     c.PoolSize,
+		PoolTimeout:  time.Duration(c.PoolTimeout),
+		MaxRetries:   c.MaxRetries,
+		MinIdleConns: c.MinIdleConns,
+		NewClient: func(name string, opt *redis.Options) *redis.Client {
+			shard := redis.NewClient(opt)
+			shard.AddHook(newPeerHook(opt.Addr, opentracing.Tag{Key: tagRedisShard, Value: name}))
+			return shard
+		},
+	})
+	conn.AddHook(&TracingHook{Statement: c.Statement})
+	registerPool(c.PoolMetrics, conn)
+	return conn
+}
+
+// newTracingHook returns TracingHook of c, client is used by PoolStatsTag
+func newTracingHook(c *Config, client *redis.Client) *TracingHook {
+	hook := &TracingHook{Statement: c.Statement}
+	if c.PoolStatsTag {
+		hook.pool = poolSnapshot(client)
+	}
+	return hook
+}
@@ -1,41 +1,47 @@
 package cache
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"go-trace/trace"
-	"io"
+	"go-trace/trace/tracetest"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 var (
-	closer io.Closer
-	tr     trace.Tracer
+	rec *tracetest.Recorder
+	tr  trace.Tracer
 )
 
 func init() {
-	conf := &trace.Config{
-		ServiceName:        "Trace-cache-service",
-		OpenReporter:       true,                           // open jaeger reporter
-		Stdlog:             true,                           // log stdout
-		ReportHost:         "127.0.0.1:6831",               // host:port -> 127.0.0.1:6831
-		SamplerType:        "const",                        //const, probabilistic, rateLimiting, or remote
-		SamplerParam:       1,                              // 0 or 1
-		FlushInterval:      time.Duration(1 * time.Second), // second, default 1
-		DisableClientTrace: false,
-	}
-	_, closer = trace.NewTracer(conf)
+	rec = tracetest.NewRecorder().Install()
 	tr = trace.StartSpan("redis-trace")
 }
 
 func TestRedisCache(t *testing.T) {
+	cn, err := net.DialTimeout("tcp", "127.0.0.1:6379", 100*time.Millisecond)
+	if err != nil {
+		t.Skipf("redis is not reachable: %v", err)
+	}
+	cn.Close()
 	conf := &Config{
 		Proto: "tcp",
 		Addr:  "127.0.0.1:6379",
 		DB:    1,
 	}
-	ctx := context.WithValue(context.Background(), trace.CtxKey, tr.GetSpan())
-	defer closer.Close()
+	ctx := tr.ContextWithSpan(context.Background())
 
 	client := New(conf)
 	defer client.Close()
@@ -50,4 +56,312 @@ func TestRedisCache(t *testing.T) {
 	}
 	client.Del(ctx, "test-1")
 	t.Logf("redis value: %v", val)
+	tr.Finish(nil)
+
+	parent, _ := rec.FindOne("redis-trace")
+	span, ok := rec.FindOne("Redis:set")
+	if !ok {
+		t.Fatalf("redis span not recorded")
+	}
+	tracetest.AssertChildOf(t, span, parent)
+	tracetest.AssertTag(t, span, trace.TagComponent, "cache/redis")
+}
+
+func TestHookPipelineErrors(t *testing.T) {
+	rec.Reset()
+	root := trace.StartSpan("pipeline")
+	ctx := root.ContextWithSpan(context.Background())
+	hook := NewTracingHook()
+
+	cmds := []redis.Cmder{
+		redis.NewStatusCmd(ctx, "multi"),
+		redis.NewStringCmd(ctx, "get", "a"),
+		redis.NewStringCmd(ctx, "incr", "b"),
+		redis.NewStringCmd(ctx, "get", "c"),
+		redis.NewSliceCmd(ctx, "exec"),
+	}
+	cmds[1].SetErr(redis.Nil)
+	cmds[2].SetErr(errors.New("ERR value is not an integer"))
+	cx, _ := hook.BeforeProcessPipeline(ctx, cmds)
+	hook.AfterProcessPipeline(cx, cmds)
+	root.Finish(nil)
+
+	parent, _ := rec.FindOne("pipeline")
+	span, _ := rec.FindOne("Redis:Transaction")
+	tracetest.AssertChildOf(t, span, parent)
+	tracetest.AssertTag(t, span, tagRedisTransaction, true)
+	tracetest.AssertTag(t, span, trace.TagError, true)
+	if idx := tracetest.LogValues(span, logRedisCmdIndex); len(idx) != 1 || idx[0] != "2" {
+		t.Fatalf("failed command index %v, want [2]", idx)
+	}
+	if name := tracetest.LogValues(span, logRedisCmd); len(name) != 1 || name[0] != "incr" {
+		t.Fatalf("failed command name %v, want [incr]", name)
+	}
+}
+
+func TestHookMissingKey(t *testing.T) {
+	rec.Reset()
+	root := trace.StartSpan("missing")
+	ctx := root.ContextWithSpan(context.Background())
+	hook := NewTracingHook()
+
+	cmd := redis.NewStringCmd(ctx, "get", "a")
+	cmd.SetErr(redis.Nil)
+	cx, _ := hook.BeforeProcess(ctx, cmd)
+	hook.AfterProcess(cx, cmd)
+	// callers returning redis.Nil are still failed
+	err := fmt.Errorf("load: %w", redis.Nil)
+	root.Finish(&err)
+
+	span, _ := rec.FindOne("Redis:get")
+	if span.Tag(trace.TagError) != nil {
+		t.Fatal("missing key should not fail the redis span")
+	}
+	parent, _ := rec.FindOne("missing")
+	tracetest.AssertTag(t, parent, trace.TagError, true)
+}
+
+func TestHookNested(t *testing.T) {
+	rec.Reset()
+	root := trace.StartSpan("nested")
+	ctx := root.ContextWithSpan(context.Background())
+	hook := NewTracingHook()
+
+	// AfterProcess must not finish a span it didn't start
+	hook.AfterProcess(ctx, redis.NewStringCmd(ctx, "get", "a"))
+	outer := redis.NewStringCmd(ctx, "get", "a")
+	outerCtx, _ := hook.BeforeProcess(ctx, outer)
+	inner := redis.NewStringCmd(outerCtx, "set", "b", "1")
+	innerCtx, _ := hook.BeforeProcess(outerCtx, inner)
+	hook.AfterProcess(innerCtx, inner)
+	hook.AfterProcess(outerCtx, outer)
+	if len(rec.Spans()) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(rec.Spans()))
+	}
+	root.Finish(nil)
+
+	parent, _ := rec.FindOne("nested")
+	outerSpan, _ := rec.FindOne("Redis:get")
+	innerSpan, _ := rec.FindOne("Redis:set")
+	tracetest.AssertChildOf(t, outerSpan, parent)
+	tracetest.AssertChildOf(t, innerSpan, outerSpan)
+	tracetest.AssertTag(t, innerSpan, trace.TagDBStatement, "set b ?")
+}
+
+func TestKeySlot(t *testing.T) {
+	cases := map[string]int{
+		"123456789":      12739,
+		"foo":            12182,
+		"{foo}.bar":      12182,
+		"user{foo}{bar}": 12182,
+		"":               0,
+	}
+	for key, want := range cases {
+		if got := keySlot(key); got != want {
+			t.Fatalf("keySlot(%q) = %d, want %d", key, got, want)
+		}
+	}
+	if key, ok := cmdKey(redis.NewCmd(context.Background(), "eval", "return 1", 1, "k")); !ok || key != "k" {
+		t.Fatalf("eval key = %q, want k", key)
+	}
+	if _, ok := cmdKey(redis.NewStatusCmd(context.Background(), "ping")); ok {
+		t.Fatal("ping has no key")
+	}
+}
+
+func TestRingPeerAddress(t *testing.T) {
+	rec.Reset()
+	root := trace.StartSpan("ring")
+	ctx := root.ContextWithSpan(context.Background())
+	client := NewRing(&Config{Addrs: []string{"127.0.0.1:1"}, MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	if err := client.Get(ctx, "foo").Err(); err == nil {
+		t.Fatal("get from closed port should fail")
+	}
+	root.Finish(nil)
+
+	span, _ := rec.FindOne("Redis:get")
+	tracetest.AssertTag(t, span, trace.TagPeerAddress, "127.0.0.1:1")
+	tracetest.AssertTag(t, span, tagRedisShard, "127.0.0.1:1")
+	tracetest.AssertTag(t, span, trace.TagError, true)
+}
+
+func TestClusterSlot(t *testing.T) {
+	rec.Reset()
+	root := trace.StartSpan("cluster")
+	ctx := root.ContextWithSpan(context.Background())
+	client := NewCluster(&Config{Addrs: []string{"127.0.0.1:1"}, MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	client.Get(ctx, "{foo}.bar")
+	root.Finish(nil)
+
+	span, _ := rec.FindOne("Redis:get")
+	tracetest.AssertTag(t, span, tagRedisSlot, 12182)
+	tracetest.AssertTag(t, span, trace.TagPeerAddress, "127.0.0.1:1")
+}
+
+// fakeRedis serves the commands used by the failover client, every other command replies nil.
+// master is the address answered to sentinel commands, stop closes the server and its connections.
+func fakeRedis(t *testing.T, master *atomic.Value) (addr string, stop func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		conns []net.Conn
+	)
+	stop = func() {
+		ln.Close()
+		mu.Lock()
+		for _, cn := range conns {
+			cn.Close()
+		}
+		mu.Unlock()
+	}
+	t.Cleanup(stop)
+	go func() {
+		for {
+			cn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, cn)
+			mu.Unlock()
+			go serveFakeRedis(cn, master)
+		}
+	}()
+	return ln.Addr().String(), stop
+}
+
+func serveFakeRedis(cn net.Conn, master *atomic.Value) {
+	defer cn.Close()
+	r := bufio.NewReader(cn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			r.ReadString('\n')
+			arg, _ := r.ReadString('\n')
+			args[i] = strings.ToLower(strings.TrimSpace(arg))
+		}
+		switch {
+		case len(args) > 1 && args[1] == "get-master-addr-by-name":
+			host, port, _ := net.SplitHostPort(master.Load().(string))
+			fmt.Fprintf(cn, "*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(host), host, len(port), port)
+		case len(args) > 1 && args[1] == "sentinels":
+			fmt.Fprint(cn, "*0\r\n")
+		case len(args) > 1 && args[0] == "subscribe":
+			fmt.Fprintf(cn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1]), args[1])
+		default:
+			fmt.Fprint(cn, "$-1\r\n")
+		}
+	}
+}
+
+func TestFailoverMasterName(t *testing.T) {
+	rec.Reset()
+	var master atomic.Value
+	first, stopFirst := fakeRedis(t, nil)
+	second, _ := fakeRedis(t, nil)
+	master.Store(first)
+	sentinel, _ := fakeRedis(t, &master)
+	root := trace.StartSpan("failover")
+	ctx := root.ContextWithSpan(context.Background())
+	client := NewFailover(&Config{MasterName: "mymaster", Addrs: []string{sentinel}, MaxRetries: 1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	get := func() {
+		if err := client.Get(ctx, "foo").Err(); err != redis.Nil {
+			t.Fatalf("get: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		get()
+	}
+	// failover, the old master is down and the sentinel answers the new one
+	master.Store(second)
+	stopFirst()
+	get()
+	root.Finish(nil)
+
+	spans := rec.FindByOperation("Redis:get")
+	if len(spans) != 4 {
+		t.Fatalf("recorded %d spans, want 4", len(spans))
+	}
+	for i, span := range spans {
+		want := first
+		if i == 3 {
+			want = second
+		}
+		tracetest.AssertTag(t, span, tagRedisMasterName, "mymaster")
+		tracetest.AssertTag(t, span, trace.TagPeerAddress, want)
+	}
+}
+
+type fakePool redis.PoolStats
+
+func (p *fakePool) PoolStats() *redis.PoolStats {
+	return (*redis.PoolStats)(p)
+}
+
+func TestPoolCollector(t *testing.T) {
+	pool := &fakePool{Hits: 5, Misses: 2, Timeouts: 1, TotalConns: 4, IdleConns: 1}
+	want := `
+# HELP redis_pool_connections Number of connections in the pool by state: total, idle, in_use or stale.
+# TYPE redis_pool_connections gauge
+redis_pool_connections{name="session",state="idle"} 1
+redis_pool_connections{name="session",state="in_use"} 3
+redis_pool_connections{name="session",state="stale"} 0
+redis_pool_connections{name="session",state="total"} 4
+# HELP redis_pool_timeouts_total Number of times a wait for a connection timed out.
+# TYPE redis_pool_timeouts_total counter
+redis_pool_timeouts_total{name="session"} 1
+`
+	c := NewPoolCollector("session", pool)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "redis_pool_connections", "redis_pool_timeouts_total"); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(c); n != 7 {
+		t.Fatalf("collected %d metrics, want 7", n)
+	}
+}
+
+func TestPoolMetrics(t *testing.T) {
+	for _, name := range []string{"sessions", "queues"} {
+		client := New(&Config{Addr: "127.0.0.1:1", PoolMetrics: name})
+		defer client.Close()
+	}
+	// the redis_pool_connections states of both clients
+	if n, err := testutil.GatherAndCount(prometheus.DefaultGatherer, "redis_pool_connections"); err != nil || n != 8 {
+		t.Fatalf("gathered %d metrics, error: %v", n, err)
+	}
+}
+
+func TestHookPoolSnapshot(t *testing.T) {
+	rec.Reset()
+	root := trace.StartSpan("pool")
+	ctx := root.ContextWithSpan(context.Background())
+	pool := &redis.PoolStats{TotalConns: 2, Timeouts: 3}
+	hook := &TracingHook{pool: func() (*redis.PoolStats, bool) { return pool, pool.IdleConns == 0 }}
+
+	cmd := redis.NewStringCmd(ctx, "get", "a")
+	cx, _ := hook.BeforeProcess(ctx, cmd)
+	hook.AfterProcess(cx, cmd)
+	pool.IdleConns = 1
+	cmd = redis.NewStringCmd(ctx, "get", "b")
+	cx, _ = hook.BeforeProcess(ctx, cmd)
+	hook.AfterProcess(cx, cmd)
+	root.Finish(nil)
+
+	spans := rec.FindByOperation("Redis:get")
+	tracetest.AssertTag(t, spans[0], tagPoolTotalConns, uint32(2))
+	tracetest.AssertTag(t, spans[0], tagPoolTimeouts, uint32(3))
+	if spans[1].Tag(tagPoolTotalConns) != nil {
+		t.Fatal("command with idle connection should not be tagged")
+	}
 }
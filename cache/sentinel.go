@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// masterAddr records the master address of a failover client.
+// The failover client shares its dialer between sentinel and master connections,
+// addresses of the sentinels are skipped. After a failover the connections to the
+// old master are closed, so the address of the last dial is the current master.
+type masterAddr struct {
+	sentinels   map[string]bool
+	dialTimeout time.Duration
+	addr        atomic.Value
+}
+
+func newMasterAddr(c *Config) *masterAddr {
+	m := &masterAddr{sentinels: make(map[string]bool, len(c.Addrs)), dialTimeout: time.Duration(c.DialTimeout)}
+	for _, addr := range c.Addrs {
+		m.sentinels[addr] = true
+	}
+	m.addr.Store("")
+	return m
+}
+
+// dialer returns the failover client dialer, it records the address of every new master connection.
+func (m *masterAddr) dialer() func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: m.dialTimeout, KeepAlive: 5 * time.Minute}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		cn, err := dialer.DialContext(ctx, network, addr)
+		if err == nil && !m.sentinels[addr] {
+			m.addr.Store(addr)
+		}
+		return cn, err
+	}
+}
+
+// Addr returns the address of the last master connection
+func (m *masterAddr) Addr() string {
+	return m.addr.Load().(string)
+}
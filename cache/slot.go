@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const slotNumber = 16384
+
+var crc16Table [256]uint16
+
+func init() {
+	for i := range crc16Table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		crc16Table[i] = crc
+	}
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by redis cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// keySlot returns the cluster slot of key, only the hash tag {...} is hashed if present
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % slotNumber)
+}
+
+// keylessCmds are commands whose first argument is not a key
+var keylessCmds = map[string]bool{
+	"auth": true, "client": true, "cluster": true, "command": true, "config": true,
+	"echo": true, "info": true, "ping": true, "publish": true, "script": true, "select": true,
+}
+
+// cmdKey returns the first key of cmd, the position is guessed from the
+// common command layout, it's used for tags only.
+func cmdKey(cmd redis.Cmder) (string, bool) {
+	args := cmd.Args()
+	name := cmd.Name()
+	switch {
+	case keylessCmds[name]:
+		return "", false
+	case name == "eval" || name == "evalsha":
+		if len(args) < 4 {
+			return "", false
+		}
+		if n, err := strconv.Atoi(fmt.Sprint(args[2])); err != nil || n == 0 {
+			return "", false
+		}
+		return fmt.Sprint(args[3]), true
+	case len(args) < 2:
+		return "", false
+	}
+	return fmt.Sprint(args[1]), true
+}
@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"log"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	tagPoolTotalConns = "db.pool.total_conns"
+	tagPoolIdleConns  = "db.pool.idle_conns"
+	tagPoolTimeouts   = "db.pool.timeouts"
+)
+
+// PoolStater is a redis client with pool stats, e.g. *redis.Client, *redis.ClusterClient and *redis.Ring
+type PoolStater interface {
+	PoolStats() *redis.PoolStats
+}
+
+// PoolCollector publishes redis pool stats as prometheus metrics, stats are read on every scrape.
+// go-redis v8 doesn't count waits for a connection, redis_pool_timeouts_total counts the waits that timed out.
+//
+//	prometheus.MustRegister(cache.NewPoolCollector("session", client))
+//
+// Set Config.PoolMetrics to register it by the constructors.
+type PoolCollector struct {
+	client   PoolStater
+	hits     *prometheus.Desc
+	misses   *prometheus.Desc
+	timeouts *prometheus.Desc
+	conns    *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*PoolCollector)(nil)
+
+// NewPoolCollector returns a PoolCollector, name is the value of the name label
+func NewPoolCollector(name string, client PoolStater) *PoolCollector {
+	labels := prometheus.Labels{"name": name}
+	return &PoolCollector{
+		client: client,
+		hits: prometheus.NewDesc("redis_pool_hits_total",
+			"Number of times a free connection was found in the pool.", nil, labels),
+		misses: prometheus.NewDesc("redis_pool_misses_total",
+			"Number of times a free connection was not found in the pool.", nil, labels),
+		timeouts: prometheus.NewDesc("redis_pool_timeouts_total",
+			"Number of times a wait for a connection timed out.", nil, labels),
+		conns: prometheus.NewDesc("redis_pool_connections",
+			"Number of connections in the pool by state: total, idle, in_use or stale.", []string{"state"}, labels),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.conns
+}
+
+// Collect implements prometheus.Collector
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(s.TotalConns), "total")
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(s.IdleConns), "idle")
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(s.TotalConns-s.IdleConns), "in_use")
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(s.StaleConns), "stale")
+}
+
+// registerPool registers the PoolCollector of client to the default prometheus registry if name is set
+func registerPool(name string, client PoolStater) {
+	if name == "" {
+		return
+	}
+	if err := prometheus.Register(NewPoolCollector(name, client)); err != nil {
+		log.Printf("cache: register pool metrics %q error: %v", name, err)
+	}
+}
+
+// poolSnapshot returns the pool stats if a command has to wait for a connection,
+// that is no connection is idle and the pool is full.
+func poolSnapshot(client *redis.Client) func() (*redis.PoolStats, bool) {
+	return func() (*redis.PoolStats, bool) {
+		s := client.PoolStats()
+		return s, s.IdleConns == 0 && int(s.TotalConns) >= client.Options().PoolSize
+	}
+}
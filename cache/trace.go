@@ -2,17 +2,22 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"go-trace/trace"
 
-	"github.com/go-redis/redis/extra/rediscmd/v8"
 	"github.com/go-redis/redis/v8"
-	// "go.opentelemetry.io/otel/trace"
+	"github.com/opentracing/opentracing-go"
 )
 
 // TracingHook .
-type TracingHook struct{}
+type TracingHook struct {
+	Statement trace.Sanitizer
+
+	pool func() (*redis.PoolStats, bool)
+}
 
 var _ redis.Hook = (*TracingHook)(nil)
 
@@ -21,67 +26,208 @@ func NewTracingHook() *TracingHook {
 	return new(TracingHook)
 }
 
+const (
+	tagRedisNumCmd      = "db.redis.num_cmd"
+	tagRedisTransaction = "db.redis.transaction"
+	logRedisCmdIndex    = "db.redis.cmd_index"
+	logRedisCmd         = "db.redis.cmd"
+	tagRedisSlot        = "db.redis.cluster_slot"
+	tagRedisMasterName  = "db.redis.master_name"
+	tagRedisShard       = "db.redis.shard"
+)
+
+// spanKey is the context key of the span started by TracingHook,
+// AfterProcess only finishes that span, never a parent one.
+type spanKey struct{}
+
 func setTags(tr *trace.Tracer) {
 	tr.SetTag(trace.Tag(trace.TagPeerService, "redis"))
 	tr.SetTag(trace.Tag(trace.TagComponent, "cache/redis"))
 	tr.SetTag(trace.Tag(trace.TagSpanKind, "client"))
 }
 
+func contextWithSpan(ctx context.Context, tr *trace.Tracer) context.Context {
+	return context.WithValue(tr.ContextWithSpan(ctx), spanKey{}, tr)
+}
+
+func spanFromContext(ctx context.Context) (*trace.Tracer, bool) {
+	tr, ok := ctx.Value(spanKey{}).(*trace.Tracer)
+	return tr, ok
+}
+
+// isTransaction report whether cmds are wrapped by MULTI/EXEC
+func isTransaction(cmds []redis.Cmder) bool {
+	return len(cmds) >= 2 && cmds[0].Name() == "multi" && cmds[len(cmds)-1].Name() == "exec"
+}
+
+// tagPool tags the pool stats if the command has to wait for a connection
+func (h TracingHook) tagPool(tr *trace.Tracer) {
+	if h.pool == nil {
+		return
+	}
+	if s, wait := h.pool(); wait {
+		tr.SetTag(
+			trace.Tag(tagPoolTotalConns, s.TotalConns),
+			trace.Tag(tagPoolIdleConns, s.IdleConns),
+			trace.Tag(tagPoolTimeouts, s.Timeouts),
+		)
+	}
+}
+
 // BeforeProcess .
-func (TracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
-	tr, ok := trace.StartSpanFromContextV2(ctx, fmt.Sprintf("Redis:%s", cmd.FullName()))
+func (h TracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	tr, ok := trace.StartSpanFromContext(ctx, fmt.Sprintf("Redis:%s", cmd.FullName()))
 	if !ok {
 		return ctx, nil
 	}
 	setTags(&tr)
-	tr.SetTag(trace.Tag(trace.TagDBStatement, rediscmd.CmdString(cmd)))
-	ctx = tr.ContextWithSpan(ctx)
-	return ctx, nil
+	tr.SetTag(trace.Tag(trace.TagDBStatement, h.Statement.Redis(cmd.Args())))
+	h.tagPool(&tr)
+	return contextWithSpan(ctx, &tr), nil
 }
 
 // AfterProcess .
-func (TracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) (err error) {
-	tr, ok := trace.SpanFromContext(ctx)
+func (TracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	tr, ok := spanFromContext(ctx)
 	if !ok {
 		return nil
 	}
-	defer tr.Finish(&err)
-	if err = cmd.Err(); err != nil {
-		recordError(ctx, &tr, err)
+	err := cmd.Err()
+	if isExpected(err) {
+		err = nil
 	}
+	tr.Finish(&err)
 	return nil
 }
 
 // BeforeProcessPipeline .
-func (TracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
-	tr, ok := trace.StartSpanFromContextV2(ctx, "Redis:Pipeline")
+func (h TracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	name := "Redis:Pipeline"
+	tx := isTransaction(cmds)
+	if tx {
+		name = "Redis:Transaction"
+	}
+	tr, ok := trace.StartSpanFromContext(ctx, name)
 	if !ok {
 		return ctx, nil
 	}
 	setTags(&tr)
-	_, cmdsString := rediscmd.CmdsString(cmds)
-	tr.SetTag(trace.Tag(trace.TagDBStatement, cmdsString))
-	tr.SetTag(trace.Tag("db.redis.num_cmd", len(cmds)))
-	ctx = tr.ContextWithSpan(ctx)
-	return ctx, nil
+	stmts := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		stmts[i] = h.Statement.Redis(cmd.Args())
+	}
+	tr.SetTag(trace.Tag(trace.TagDBStatement, h.Statement.Truncate(strings.Join(stmts, "\n"))))
+	tr.SetTag(trace.Tag(tagRedisNumCmd, len(cmds)))
+	h.tagPool(&tr)
+	if tx {
+		tr.SetTag(trace.Tag(tagRedisTransaction, true))
+	}
+	return contextWithSpan(ctx, &tr), nil
 }
 
-// AfterProcessPipeline .
-func (TracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) (err error) {
-	span, ok := trace.SpanFromContext(ctx)
+// AfterProcessPipeline records every failed command with its index and name,
+// the span is marked failed if any command failed.
+func (TracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	tr, ok := spanFromContext(ctx)
 	if !ok {
 		return nil
 	}
-	defer span.Finish(&err)
-	if err := cmds[0].Err(); err != nil {
-		recordError(ctx, &span, err)
+	var failed error
+	for i, cmd := range cmds {
+		err := cmd.Err()
+		if err == nil || isExpected(err) {
+			continue
+		}
+		if failed == nil {
+			failed = err
+		}
+		tr.SetLog(
+			trace.LogString(trace.LogEvent, "error"),
+			trace.LogInt(logRedisCmdIndex, i),
+			trace.LogString(logRedisCmd, cmd.FullName()),
+			trace.LogString(trace.LogMessage, err.Error()),
+		)
+	}
+	if failed != nil {
+		tr.SetTag(trace.Tag(trace.TagError, true))
+	}
+	tr.Finish(nil)
+	return nil
+}
+
+// isExpected reports whether err should not mark the span failed, redis.Nil is a missing key.
+// it's not registered by trace.IgnoreErrors so spans of callers returning redis.Nil still fail.
+func isExpected(err error) bool {
+	return errors.Is(err, redis.Nil) || trace.IsExpectedError(err)
+}
+
+// peerHook tags the span started by TracingHook with the node that serves the command,
+// it's attached to node clients so it runs after the node is selected. The tags are set
+// after the command, when the connection to the node is dialed.
+type peerHook struct {
+	addr func() string
+	tags []opentracing.Tag
+}
+
+var _ redis.Hook = peerHook{}
+
+func newPeerHook(addr string, tags ...opentracing.Tag) peerHook {
+	return peerHook{addr: func() string { return addr }, tags: tags}
+}
+
+// BeforeProcess .
+func (peerHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+// AfterProcess .
+func (h peerHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if tr, ok := spanFromContext(ctx); ok {
+		tr.SetTag(trace.Tag(trace.TagPeerAddress, h.addr()))
+		tr.SetTag(h.tags...)
+	}
+	return nil
+}
+
+// BeforeProcessPipeline .
+func (peerHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+// AfterProcessPipeline logs the node, cluster pipelines are sent to several nodes concurrently.
+func (h peerHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	if tr, ok := spanFromContext(ctx); ok {
+		tr.SetLog(trace.LogString(trace.LogEvent, "redis.node"), trace.LogString(trace.LogAddr, h.addr()))
 	}
 	return nil
 }
 
-func recordError(ctx context.Context, t *trace.Tracer, err error) {
-	if err != redis.Nil {
-		t.SetTag(trace.Tag(trace.TagError, true))
-		t.SetLog(trace.LogString(trace.LogEvent, "redis error"), trace.LogString(trace.LogMessage, err.Error()))
+// slotHook tags the span started by TracingHook with the cluster slot of the command key
+type slotHook struct{}
+
+var _ redis.Hook = slotHook{}
+
+// BeforeProcess .
+func (slotHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	if tr, ok := spanFromContext(ctx); ok {
+		if key, ok := cmdKey(cmd); ok {
+			tr.SetTag(trace.Tag(tagRedisSlot, keySlot(key)))
+		}
 	}
+	return ctx, nil
+}
+
+// AfterProcess .
+func (slotHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+// BeforeProcessPipeline .
+func (slotHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+// AfterProcessPipeline .
+func (slotHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
 }
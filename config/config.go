@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"go-trace/cache"
+	"go-trace/http"
+	"go-trace/orm"
+	"go-trace/trace"
+)
+
+// Supported config file formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// Config all configs of go-trace, a section is nil if it is not present
+// in the config file or the environment.
+//
+//	trace:
+//	  service_name: order-service
+//	  report_host: 127.0.0.1:6831
+//	  flush_interval: 1s
+//	http:
+//	  addr: :8888
+//	  read_timeout: 300ms
+type Config struct {
+	Trace      *trace.Config
+	HTTP       *http.Config
+	HTTPClient *http.ClientConfig
+	Redis      *cache.Config
+	ORM        *orm.Config
+}
+
+// Error describes an invalid config field.
+type Error struct {
+	Section string
+	Field   string
+	Reason  string
+}
+
+func (e *Error) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("config: %s: %s", e.Section, e.Reason)
+	}
+	return fmt.Sprintf("config: %s.%s: %s", e.Section, e.Field, e.Reason)
+}
+
+// Load reads config from path, the format is detected by the file extension
+// (.yaml, .yml, .json, .toml), then environment variables are applied and
+// the result is validated.
+func Load(path string) (*Config, error) {
+	format, err := formatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LoadBytes(data, format)
+}
+
+// LoadBytes same as Load, reads config from data.
+func LoadBytes(data []byte, format string) (*Config, error) {
+	raw, err := unmarshal(data, format)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Config)
+	if err = decode(c, raw); err != nil {
+		return nil, err
+	}
+	if err = c.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err = c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FromEnv returns config only from environment variables.
+func FromEnv() (*Config, error) {
+	c := new(Config)
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func formatOf(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	case ".toml":
+		return FormatTOML, nil
+	}
+	return "", &Error{Section: "file", Field: path, Reason: "unsupported format, use yaml, json or toml"}
+}
+
+// Validate check every present section.
+func (c *Config) Validate() error {
+	if c.Trace != nil {
+		if err := c.Trace.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.HTTP != nil {
+		if c.HTTP.Addr == "" {
+			return &Error{Section: "http", Field: "Addr", Reason: "address is required"}
+		}
+		if c.HTTP.ReadTimeout < 0 || c.HTTP.WriteTimeout < 0 || c.HTTP.Timeout < 0 {
+			return &Error{Section: "http", Reason: "timeout must not be negative"}
+		}
+	}
+	if c.HTTPClient != nil {
+		if c.HTTPClient.Dial < 0 || c.HTTPClient.Timeout < 0 || c.HTTPClient.KeepAlive < 0 {
+			return &Error{Section: "http_client", Reason: "timeout must not be negative"}
+		}
+	}
+	if c.Redis != nil {
+		if c.Redis.Addr == "" && len(c.Redis.Addrs) == 0 {
+			return &Error{Section: "redis", Field: "Addr", Reason: "addr or addrs is required"}
+		}
+		if c.Redis.PoolSize < 0 || c.Redis.MaxRetries < 0 || c.Redis.MinIdleConns < 0 {
+			return &Error{Section: "redis", Reason: "pool settings must not be negative"}
+		}
+		if err := c.Redis.Statement.Validate(); err != nil {
+			return &Error{Section: "redis", Field: "Statement", Reason: err.Error()}
+		}
+	}
+	if c.ORM != nil {
+		if c.ORM.DSN == "" {
+			return &Error{Section: "orm", Field: "DSN", Reason: "dsn is required"}
+		}
+		if err := c.ORM.Validate(); err != nil {
+			return &Error{Section: "orm", Reason: err.Error()}
+		}
+	}
+	return nil
+}
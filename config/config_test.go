@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"go-trace/trace"
+)
+
+const yamlConf = `
+trace:
+  service_name: order-service
+  report_host: 127.0.0.1:6831
+  sampler_type: const
+  sampler_param: 1
+  flush_interval: 1s
+http:
+  addr: ":8888"
+  read_timeout: 300ms
+redis:
+  addrs: ["127.0.0.1:7000", "127.0.0.1:7001"]
+  pool_size: 10
+`
+
+const jsonConf = `{
+  "trace": {"ServiceName": "order-service", "SamplerType": "probabilistic", "SamplerParam": 0.5},
+  "http_client": {"dial": "100ms", "timeout": "300ms", "keep_alive": "60s"},
+  "orm": {"dsn": "root:@tcp(127.0.0.1:3306)/mysql", "active": 30, "idle_timeout": "1h"}
+}`
+
+const tomlConf = `
+[trace]
+service_name = "order-service"
+sampler_type = "const"
+sampler_param = 0
+flush_interval = "500ms"
+
+[trace.exporter]
+type = "zipkin"
+endpoint = "http://127.0.0.1:9411/api/v2/spans"
+batch_size = 50
+
+[trace.exporter.headers]
+X-Token = "abc"
+`
+
+func TestLoadBytes(t *testing.T) {
+	c, err := LoadBytes([]byte(yamlConf), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Trace.ServiceName != "order-service" || c.Trace.FlushInterval != time.Second {
+		t.Fatalf("trace config %+v", c.Trace)
+	}
+	if c.HTTP.ReadTimeout != 300*time.Millisecond || len(c.Redis.Addrs) != 2 || c.Redis.PoolSize != 10 {
+		t.Fatalf("http %+v redis %+v", c.HTTP, c.Redis)
+	}
+	if c.ORM != nil || c.HTTPClient != nil {
+		t.Fatal("absent sections should be nil")
+	}
+
+	c, err = LoadBytes([]byte(jsonConf), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Trace.SamplerParam != 0.5 || c.HTTPClient.KeepAlive != time.Minute || c.ORM.IdleTimeout != time.Hour || c.ORM.Active != 30 {
+		t.Fatalf("json config %+v %+v %+v", c.Trace, c.HTTPClient, c.ORM)
+	}
+
+	c, err = LoadBytes([]byte(tomlConf), FormatTOML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Trace.FlushInterval != 500*time.Millisecond {
+		t.Fatalf("toml config %+v", c.Trace)
+	}
+	if e := c.Trace.Exporter; e == nil || e.Type != trace.ExporterZipkin || e.BatchSize != 50 || e.Headers["X-Token"] != "abc" {
+		t.Fatalf("toml exporter %+v", c.Trace.Exporter)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	if _, err := LoadBytes([]byte("trace:\n  service_nam: x\n"), FormatYAML); err == nil {
+		t.Fatal("unknown field should fail")
+	}
+	if _, err := LoadBytes([]byte("trace:\n  service_name: x\n  flush_interval: soon\n"), FormatYAML); err == nil {
+		t.Fatal("invalid duration should fail")
+	}
+	_, err := LoadBytes([]byte("trace:\n  service_name: x\n  sampler_type: always\n"), FormatYAML)
+	if !errors.Is(err, trace.ErrInvalidConfig) {
+		t.Fatalf("want trace.ErrInvalidConfig, got %v", err)
+	}
+	var ce *Error
+	if _, err = LoadBytes([]byte("redis:\n  db: 1\n"), FormatYAML); !errors.As(err, &ce) || ce.Section != "redis" {
+		t.Fatalf("want redis config error, got %v", err)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	env := map[string]string{
+		"JAEGER_SERVICE_NAME":                "env-service",
+		"JAEGER_AGENT_PORT":                  "6832",
+		"JAEGER_REPORTER_FLUSH_INTERVAL":     "2s",
+		"GOTRACE_TRACE_DISABLE_CLIENT_TRACE": "true",
+		"GOTRACE_REDIS_ADDR":                 "127.0.0.1:6379",
+		"GOTRACE_HTTP_CLIENT_TIMEOUT":        "1s",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	c, err := LoadBytes([]byte(yamlConf), FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Trace.ServiceName != "env-service" || c.Trace.ReportHost != "127.0.0.1:6832" ||
+		c.Trace.FlushInterval != 2*time.Second || !c.Trace.DisableClientTrace {
+		t.Fatalf("trace env %+v", c.Trace)
+	}
+	if c.Redis.Addr != "127.0.0.1:6379" || c.HTTPClient.Timeout != time.Second {
+		t.Fatalf("env %+v %+v", c.Redis, c.HTTPClient)
+	}
+}
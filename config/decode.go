@@ -0,0 +1,206 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func unmarshal(data []byte, format string) (map[string]interface{}, error) {
+	raw := map[string]interface{}{}
+	switch format {
+	case FormatYAML:
+		var v map[interface{}]interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return stringMap(v), nil
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+	case FormatTOML:
+		if _, err := toml.Decode(string(data), &raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, &Error{Section: "file", Field: format, Reason: "unsupported format, use yaml, json or toml"}
+	}
+	return raw, nil
+}
+
+// stringMap converts yaml map keys to string
+func stringMap(m map[interface{}]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[fmt.Sprint(k)] = stringValue(v)
+	}
+	return out
+}
+
+func stringValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		return stringMap(val)
+	case []interface{}:
+		for i := range val {
+			val[i] = stringValue(val[i])
+		}
+	}
+	return v
+}
+
+// normalize "service_name", "service-name" and "ServiceName" to the same key
+func normalize(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+func decode(dst interface{}, raw map[string]interface{}) error {
+	return decodeStruct(reflect.ValueOf(dst).Elem(), raw, "")
+}
+
+func decodeStruct(v reflect.Value, raw map[string]interface{}, path string) error {
+	fields := map[string]int{}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).PkgPath != "" {
+			continue
+		}
+		fields[normalize(v.Type().Field(i).Name)] = i
+	}
+	for key, val := range raw {
+		i, ok := fields[normalize(key)]
+		if !ok {
+			return &Error{Section: strings.TrimPrefix(path, "."), Field: key, Reason: "unknown field"}
+		}
+		if err := assign(v.Field(i), val, path+"."+v.Type().Field(i).Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func assign(v reflect.Value, raw interface{}, path string) error {
+	if raw == nil {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assign(v.Elem(), raw, path)
+	}
+	invalid := func(reason string) error {
+		return &Error{Section: strings.TrimPrefix(path, "."), Reason: fmt.Sprintf("%s, got %v (%T)", reason, raw, raw)}
+	}
+	if v.Type() == durationType {
+		d, err := toDuration(raw)
+		if err != nil {
+			return invalid("invalid duration")
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return invalid("want a table")
+		}
+		return decodeStruct(v, m, path)
+	case reflect.String:
+		v.SetString(fmt.Sprint(raw))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(fmt.Sprint(raw))
+		if err != nil {
+			return invalid("invalid bool")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(fmt.Sprint(raw), 10, 64)
+		if err != nil || v.OverflowInt(n) {
+			return invalid("invalid integer")
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(fmt.Sprint(raw), 10, 64)
+		if err != nil || v.OverflowUint(n) {
+			return invalid("invalid unsigned integer")
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(fmt.Sprint(raw), 64)
+		if err != nil {
+			return invalid("invalid number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []interface{}
+		switch val := raw.(type) {
+		case []interface{}:
+			items = val
+		case string:
+			// comma separated, e.g. from environment
+			for _, item := range strings.Split(val, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		default:
+			return invalid("want a list")
+		}
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := assign(s.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Map:
+		m, ok := raw.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return invalid("want a table")
+		}
+		out := reflect.MakeMapWithSize(v.Type(), len(m))
+		for key, item := range m {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := assign(elem, item, path+"."+key); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(out)
+	default:
+		return invalid("unsupported field type " + v.Type().String())
+	}
+	return nil
+}
+
+// toDuration parse "300ms", "1s" or a number of nanoseconds
+func toDuration(raw interface{}) (time.Duration, error) {
+	switch val := raw.(type) {
+	case string:
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return time.Duration(n), nil
+		}
+		return time.ParseDuration(val)
+	case int:
+		return time.Duration(val), nil
+	case int64:
+		return time.Duration(val), nil
+	case json.Number:
+		n, err := val.Int64()
+		return time.Duration(n), err
+	}
+	return 0, fmt.Errorf("invalid duration %v", raw)
+}
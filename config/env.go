@@ -0,0 +1,122 @@
+package config
+
+import (
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"go-trace/trace"
+)
+
+// EnvPrefix prefix of the environment variables, e.g. GOTRACE_REDIS_ADDR, GOTRACE_ORM_DSN
+const EnvPrefix = "GOTRACE"
+
+// jaeger-client-go environment variables
+const (
+	envServiceName        = "JAEGER_SERVICE_NAME"
+	envAgentHost          = "JAEGER_AGENT_HOST"
+	envAgentPort          = "JAEGER_AGENT_PORT"
+	envSamplerType        = "JAEGER_SAMPLER_TYPE"
+	envSamplerParam       = "JAEGER_SAMPLER_PARAM"
+	envReporterLogSpans   = "JAEGER_REPORTER_LOG_SPANS"
+	envReporterFlushInter = "JAEGER_REPORTER_FLUSH_INTERVAL"
+
+	defaultAgentHost = "127.0.0.1"
+	defaultAgentPort = "6831"
+)
+
+// applyEnv override config by environment variables,
+// GOTRACE_<SECTION>_<FIELD> for every section, JAEGER_* for the trace section.
+func (c *Config) applyEnv() error {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		section := envName(v.Type().Field(i).Name)
+		if err := applyStructEnv(v.Field(i), EnvPrefix+"_"+section+"_", strings.ToLower(section)); err != nil {
+			return err
+		}
+	}
+	return c.applyJaegerEnv()
+}
+
+func applyStructEnv(v reflect.Value, prefix, path string) error {
+	typ := v.Type().Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		val, ok := os.LookupEnv(prefix + envName(name))
+		if !ok {
+			continue
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(typ))
+		}
+		if err := assign(v.Elem().Field(i), val, path+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) applyJaegerEnv() error {
+	env := map[string]string{
+		envServiceName:        "ServiceName",
+		envSamplerType:        "SamplerType",
+		envSamplerParam:       "SamplerParam",
+		envReporterLogSpans:   "OpenReporter",
+		envReporterFlushInter: "FlushInterval",
+	}
+	for key, field := range env {
+		val, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if c.Trace == nil {
+			c.Trace = new(trace.Config)
+		}
+		if err := assign(reflect.ValueOf(c.Trace).Elem().FieldByName(field), val, "trace."+field); err != nil {
+			return err
+		}
+	}
+	host, hostOk := os.LookupEnv(envAgentHost)
+	port, portOk := os.LookupEnv(envAgentPort)
+	if !hostOk && !portOk {
+		return nil
+	}
+	if c.Trace == nil {
+		c.Trace = new(trace.Config)
+	}
+	if h, p, err := net.SplitHostPort(c.Trace.ReportHost); err == nil {
+		if !hostOk {
+			host = h
+		}
+		if !portOk {
+			port = p
+		}
+	}
+	if host == "" {
+		host = defaultAgentHost
+	}
+	if port == "" {
+		port = defaultAgentPort
+	}
+	c.Trace.ReportHost = net.JoinHostPort(host, port)
+	return nil
+}
+
+// envName converts field name to upper snake case, e.g. DisableClientTrace -> DISABLE_CLIENT_TRACE
+func envName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
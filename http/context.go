@@ -1,8 +1,13 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
+
+	"go-trace/trace"
 
 	"github.com/gin-gonic/gin"
 )
@@ -41,7 +46,8 @@ func (c *Context) Jsonify(data interface{}) {
 
 // GinLogFormatter log formatter function
 func GinLogFormatter(param gin.LogFormatterParams) string {
-	return fmt.Sprintf("[%s] %d %s %s %s (%s) %s %s\n",
+	traceID, spanID := logIDs(param)
+	return fmt.Sprintf("[%s] %d %s %s %s (%s) %s %s %s %s\n",
 		param.TimeStamp.Format("20060102 15:04:05"),
 		param.StatusCode,
 		param.Method,
@@ -49,6 +55,54 @@ func GinLogFormatter(param gin.LogFormatterParams) string {
 		param.Request.Proto,
 		param.ClientIP,
 		param.Latency,
+		traceID,
+		spanID,
 		param.ErrorMessage,
 	)
 }
+
+// accessLog is a line of GinJSONLogFormatter
+type accessLog struct {
+	Time      string  `json:"time"`
+	Status    int     `json:"status"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Proto     string  `json:"proto"`
+	ClientIP  string  `json:"client_ip"`
+	LatencyMS float64 `json:"latency_ms"`
+	BodySize  int     `json:"body_size"`
+	TraceID   string  `json:"trace_id,omitempty"`
+	SpanID    string  `json:"span_id,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// GinJSONLogFormatter log formatter function, writes a JSON line per request
+func GinJSONLogFormatter(param gin.LogFormatterParams) string {
+	line := accessLog{
+		Time:      param.TimeStamp.Format(time.RFC3339Nano),
+		Status:    param.StatusCode,
+		Method:    param.Method,
+		Path:      param.Path,
+		Proto:     param.Request.Proto,
+		ClientIP:  param.ClientIP,
+		LatencyMS: float64(param.Latency) / float64(time.Millisecond),
+		BodySize:  param.BodySize,
+		Error:     strings.TrimSpace(param.ErrorMessage),
+	}
+	if ids, ok := trace.IDsFromContext(param.Request.Context()); ok {
+		line.TraceID, line.SpanID = ids.TraceID, ids.SpanID
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return ""
+	}
+	return string(data) + "\n"
+}
+
+// logIDs returns the trace and span id set by the Trace middleware, "-" if not traced
+func logIDs(param gin.LogFormatterParams) (string, string) {
+	if ids, ok := trace.IDsFromContext(param.Request.Context()); ok {
+		return ids.TraceID, ids.SpanID
+	}
+	return "-", "-"
+}
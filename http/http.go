@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,10 +20,12 @@ var (
 
 // Config http server configure
 type Config struct {
-	Addr         string
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
-	Timeout      time.Duration
+	Addr          string
+	ReadTimeout   time.Duration
+	WriteTimeout  time.Duration
+	Timeout       time.Duration
+	TraceIDHeader string   // response header of the trace id, e.g. X-Trace-Id, empty disables
+	TraceExclude  []string // path globs not traced, e.g. /healthz, /static/**
 }
 
 // Engine .
@@ -36,10 +39,26 @@ func NewEngine(c *Config) *Engine {
 	e := gin.New()
 	engine := &Engine{Engine: e, Conf: c}
 	engine.Use(gin.LoggerWithFormatter(GinLogFormatter))
-	engine.Use(gin.Recovery(), Trace())
+	opts := []Option{}
+	if c.TraceIDHeader != "" {
+		opts = append(opts, WithTraceIDHeader(c.TraceIDHeader))
+	}
+	if len(c.TraceExclude) > 0 {
+		opts = append(opts, WithExcludePaths(c.TraceExclude...))
+	}
+	engine.Use(gin.Recovery(), Trace(opts...))
 	return engine
 }
 
+// MountMetrics serves prometheus metrics of g at path, default /metrics.
+// g nil is the default registry, pass the registry of trace.Config.MetricsRegisterer if it's set.
+func (e *Engine) MountMetrics(path string, g prometheus.Gatherer) {
+	if path == "" {
+		path = "/metrics"
+	}
+	e.GET(path, gin.WrapH(trace.MetricsHandler(g)))
+}
+
 // Start start http server
 func Start(c *Config, e *Engine) {
 	svr = &http.Server{
@@ -69,7 +88,14 @@ func Shutdown() {
 	}
 }
 
-// InitTracer init server trace
-func InitTracer(c *trace.Config) {
-	_, closer = trace.NewTracer(c)
+// InitTracer init server trace, returns error if config is invalid.
+// If the agent can't be reached the server keeps running with a no-op tracer.
+func InitTracer(c *trace.Config) error {
+	var err error
+	_, closer, err = trace.NewTracerE(c)
+	if errors.Is(err, trace.ErrAgentUnreachable) {
+		log.Warnf("Init tracer: %v", err)
+		return nil
+	}
+	return err
 }
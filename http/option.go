@@ -0,0 +1,133 @@
+package http
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTraceIDHeader response header of the trace id
+const DefaultTraceIDHeader = "X-Trace-Id"
+
+// Option Trace middleware option
+type Option func(*options)
+
+type options struct {
+	traceIDHeader string
+	spanName      func(*gin.Context) string
+	includePaths  []string
+	excludePaths  []string
+	methods       map[string]bool
+	filters       []func(*gin.Context) bool
+}
+
+// WithTraceIDHeader writes the trace id to the response header name, default DefaultTraceIDHeader
+func WithTraceIDHeader(name string) Option {
+	return func(o *options) {
+		if name == "" {
+			name = DefaultTraceIDHeader
+		}
+		o.traceIDHeader = name
+	}
+}
+
+// WithSpanName names server spans by fn, default SpanName
+func WithSpanName(fn func(*gin.Context) string) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.spanName = fn
+		}
+	}
+}
+
+// WithIncludePaths traces only requests whose path matches one of the globs,
+// globs use path.Match syntax, a trailing "/**" matches every path below the prefix.
+func WithIncludePaths(globs ...string) Option {
+	mustValidGlobs(globs)
+	return func(o *options) {
+		o.includePaths = append(o.includePaths, globs...)
+	}
+}
+
+// WithExcludePaths skips requests whose path matches one of the globs, e.g. "/healthz", "/static/**"
+func WithExcludePaths(globs ...string) Option {
+	mustValidGlobs(globs)
+	return func(o *options) {
+		o.excludePaths = append(o.excludePaths, globs...)
+	}
+}
+
+// WithMethods traces only requests of the methods, e.g. "GET", "POST"
+func WithMethods(methods ...string) Option {
+	return func(o *options) {
+		if o.methods == nil {
+			o.methods = map[string]bool{}
+		}
+		for _, method := range methods {
+			o.methods[strings.ToUpper(method)] = true
+		}
+	}
+}
+
+// WithFilter traces only requests that fn returns true for, filters are combined with and.
+func WithFilter(fn func(*gin.Context) bool) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.filters = append(o.filters, fn)
+		}
+	}
+}
+
+// traced reports whether the request of c passes the path, method and func filters
+func (o *options) traced(c *gin.Context) bool {
+	p := c.Request.URL.Path
+	if len(o.includePaths) > 0 && !matchPath(o.includePaths, p) {
+		return false
+	}
+	if matchPath(o.excludePaths, p) {
+		return false
+	}
+	if o.methods != nil && !o.methods[c.Request.Method] {
+		return false
+	}
+	for _, fn := range o.filters {
+		if !fn(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPath(globs []string, p string) bool {
+	for _, glob := range globs {
+		if prefix := strings.TrimSuffix(glob, "/**"); prefix != glob {
+			if p == prefix || strings.HasPrefix(p, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(glob, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// mustValidGlobs panics if a glob is malformed, like regexp.MustCompile
+func mustValidGlobs(globs []string) {
+	for _, glob := range globs {
+		if _, err := path.Match(strings.TrimSuffix(glob, "/**"), ""); err != nil {
+			panic(fmt.Sprintf("http: invalid path glob %q: %v", glob, err))
+		}
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{spanName: SpanName}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
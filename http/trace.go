@@ -5,6 +5,8 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptrace"
+	"runtime/debug"
+	"strconv"
 
 	"go-trace/trace"
 
@@ -42,33 +44,99 @@ func (c closeTracker) Close() error {
 }
 
 // Trace is trace middleware
-func Trace() gin.HandlerFunc {
+func Trace(opts ...Option) gin.HandlerFunc {
+	o := newOptions(opts)
 	return func(c *gin.Context) {
 		cx := NewContext(c)
 		var t trace.Tracer
 		// if request header include span return child startSpan, else return parent startSpan
 		spanCtx, err := trace.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(c.Request.Header))
+		if !o.traced(c) {
+			// no span of its own, downstream calls still continue the incoming trace
+			if err == nil {
+				reqCtx := trace.ContextWithSpanContext(cx, spanCtx)
+				cx.Set(trace.CtxKey, opentracing.SpanFromContext(reqCtx))
+				cx.Request = cx.Request.WithContext(reqCtx)
+			}
+			cx.Next()
+			return
+		}
 		if err != nil {
-			t = trace.StartSpan(c.Request.URL.Path)
+			t = trace.StartSpan(o.spanName(c), ext.SpanKindRPCServer)
 		} else {
-			t = trace.StartSpan(c.Request.URL.Path, ext.RPCServerOption(spanCtx))
+			t = trace.StartSpan(o.spanName(c), ext.RPCServerOption(spanCtx))
 		}
 		t.SetTag(trace.Tag(trace.TagComponent, defaultComponentName))
 		t.SetTag(trace.Tag(trace.TagHTTPMethod, c.Request.Method))
 		t.SetTag(trace.Tag(trace.TagHTTPURL, c.Request.URL.String()))
+		if o.traceIDHeader != "" {
+			if ids, ok := trace.SpanIDs(t.GetSpan()); ok {
+				c.Header(o.traceIDHeader, ids.TraceID)
+			}
+		}
 		reqCtx := trace.ContextWithSpan(cx, t.GetSpan())
 		if !trace.DisableClientTrace {
 			clientTrace := Tracer{t}
 			reqCtx = httptrace.WithClientTrace(reqCtx, clientTrace.ClientTrace())
 		}
+		// gin.Context.Value only supports string keys, handlers passing c as ctx resolve the span by CtxKey
 		cx.Set(trace.CtxKey, t.GetSpan())
 		// set http.Request context, because client.Get(ctx) use http.Request.Context()
 		cx.Request = cx.Request.WithContext(reqCtx)
+		defer func() {
+			// log the panic and let gin.Recovery write the response
+			if r := recover(); r != nil {
+				t.SetTag(trace.Tag(trace.TagHTTPStatusCode, int64(http.StatusInternalServerError)))
+				t.SetTag(trace.Tag(trace.TagError, true))
+				t.SetLog(
+					trace.LogString(trace.LogEvent, "panic"),
+					trace.LogString(trace.LogMessage, fmt.Sprint(r)),
+					trace.LogString(trace.LogStack, string(debug.Stack())),
+				)
+				t.Finish(nil)
+				panic(r)
+			}
+		}()
 		cx.Next()
+		setResponseTags(&t, c)
 		t.Finish(nil)
 	}
 }
 
+// SpanName returns "METHOD route" of the matched route, e.g. "GET /users/:id",
+// unmatched requests are named by the status, e.g. "HTTP 404".
+func SpanName(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return c.Request.Method + " " + route
+	}
+	return "HTTP " + strconv.Itoa(c.Writer.Status())
+}
+
+// setResponseTags tags the response of c, 5xx and errors of c.Errors mark the span as failed.
+func setResponseTags(t *trace.Tracer, c *gin.Context) {
+	status := c.Writer.Status()
+	t.SetTag(trace.Tag(trace.TagHTTPStatusCode, int64(status)))
+	size := c.Writer.Size()
+	if size < 0 {
+		// nothing written
+		size = 0
+	}
+	t.SetTag(trace.Tag(trace.TagHTTPResponseSize, int64(size)))
+	if route := c.FullPath(); route != "" {
+		t.SetTag(trace.Tag(trace.TagHTTPRoute, route))
+	}
+	t.SetTag(trace.Tag(trace.TagHTTPClientIP, c.ClientIP()))
+	if ua := c.Request.UserAgent(); ua != "" {
+		t.SetTag(trace.Tag(trace.TagHTTPUserAgent, ua))
+	}
+	for _, err := range c.Errors {
+		t.SetError(err.Err)
+	}
+	if status >= http.StatusInternalServerError {
+		t.SetTag(trace.Tag(trace.TagError, true))
+	}
+}
+
 // RoundTrip ...
 func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	rt := t.RoundTripper
@@ -93,7 +161,6 @@ func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	tr.Inject(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
 	resp, err := rt.RoundTrip(req)
 	if err != nil {
-		tr.SetTag(trace.Tag(trace.TagError, true))
 		tr.Finish(&err)
 		return resp, err
 	}
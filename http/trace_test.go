@@ -0,0 +1,254 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go-trace/trace"
+	"go-trace/trace/tracetest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+func newTestEngine(formatter gin.LogFormatter, out *bytes.Buffer, opts ...Option) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: formatter, Output: out}), Trace(opts...))
+	e.GET("/users/:id", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	return e
+}
+
+func TestTraceIDHeader(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		header string
+	}{
+		{"disabled", nil, ""},
+		{"default", []Option{WithTraceIDHeader("")}, DefaultTraceIDHeader},
+		{"custom", []Option{WithTraceIDHeader("X-Request-Trace")}, "X-Request-Trace"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec.Reset()
+			w := httptest.NewRecorder()
+			newTestEngine(GinLogFormatter, &bytes.Buffer{}, tc.opts...).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+			spans := rec.Spans()
+			if len(spans) != 1 {
+				t.Fatalf("spans: %d", len(spans))
+			}
+			if tc.header == "" {
+				if got := w.Header().Get(DefaultTraceIDHeader); got != "" {
+					t.Fatalf("unexpected header: %s", got)
+				}
+				return
+			}
+			if got, want := w.Header().Get(tc.header), strconv.Itoa(spans[0].SpanContext.TraceID); got != want {
+				t.Fatalf("header %s: %q, want %q", tc.header, got, want)
+			}
+		})
+	}
+}
+
+func TestLogFormatter(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+
+	out := &bytes.Buffer{}
+	newTestEngine(GinLogFormatter, out).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	sc := rec.Spans()[0].SpanContext
+	if want := " " + strconv.Itoa(sc.TraceID) + " " + strconv.Itoa(sc.SpanID) + " "; !strings.Contains(out.String(), want) {
+		t.Fatalf("log %q does not contain ids %q", out.String(), want)
+	}
+
+	rec.Reset()
+	out.Reset()
+	newTestEngine(GinJSONLogFormatter, out).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	sc = rec.Spans()[0].SpanContext
+	var line accessLog
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("unmarshal %q: %v", out.String(), err)
+	}
+	if line.TraceID != strconv.Itoa(sc.TraceID) || line.SpanID != strconv.Itoa(sc.SpanID) {
+		t.Fatalf("ids: %s %s, want %d %d", line.TraceID, line.SpanID, sc.TraceID, sc.SpanID)
+	}
+	if line.Status != http.StatusOK || line.Method != http.MethodGet || line.Path != "/users/1" {
+		t.Fatalf("line: %+v", line)
+	}
+}
+
+func TestResponseTags(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(gin.RecoveryWithWriter(ioutil.Discard), Trace())
+	e.GET("/users/:id", func(c *gin.Context) { c.String(http.StatusOK, "hello") })
+	e.GET("/fail", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	e.GET("/errors", func(c *gin.Context) {
+		c.Error(errors.New("bad input"))
+		c.Status(http.StatusBadRequest)
+	})
+	e.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	for _, tc := range []struct {
+		path   string
+		status int64
+		failed bool
+	}{
+		{"/users/1", http.StatusOK, false},
+		{"/fail", http.StatusServiceUnavailable, true},
+		{"/errors", http.StatusBadRequest, true},
+		{"/panic", http.StatusInternalServerError, true},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			rec.Reset()
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("User-Agent", "probe/1.0")
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+			if int64(w.Code) != tc.status {
+				t.Fatalf("code: %d, want %d", w.Code, tc.status)
+			}
+			spans := rec.Spans()
+			if len(spans) != 1 {
+				t.Fatalf("spans: %d", len(spans))
+			}
+			span := spans[0]
+			tracetest.AssertTag(t, span, trace.TagHTTPStatusCode, tc.status)
+			tracetest.AssertTag(t, span, trace.TagSpanKind, ext.SpanKindRPCServerEnum)
+			if failed := span.Tag(trace.TagError) == true; failed != tc.failed {
+				t.Fatalf("error: %v, want %v", failed, tc.failed)
+			}
+			if tc.path == "/panic" {
+				if msg := tracetest.LogValues(span, trace.LogMessage); len(msg) != 1 || msg[0] != "boom" {
+					t.Fatalf("panic message: %v", msg)
+				}
+				return
+			}
+			tracetest.AssertTag(t, span, trace.TagHTTPUserAgent, "probe/1.0")
+			tracetest.AssertTag(t, span, trace.TagHTTPClientIP, "192.0.2.1")
+			if tc.path == "/users/1" {
+				tracetest.AssertTag(t, span, trace.TagHTTPRoute, "/users/:id")
+				tracetest.AssertTag(t, span, trace.TagHTTPResponseSize, int64(len("hello")))
+			}
+			if tc.path == "/errors" {
+				if msg := tracetest.LogValues(span, trace.LogMessage); len(msg) != 1 || msg[0] != "bad input" {
+					t.Fatalf("error message: %v", msg)
+				}
+			}
+		})
+	}
+}
+
+func TestSpanName(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		method string
+		path   string
+		want   string
+	}{
+		{"route", nil, http.MethodGet, "/users/1", "GET /users/:id"},
+		{"not found", nil, http.MethodGet, "/missing/1", "HTTP 404"},
+		{"custom", []Option{WithSpanName(func(c *gin.Context) string { return "api " + c.FullPath() })}, http.MethodPost, "/users/2", "api /users/:id"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec.Reset()
+			e := gin.New()
+			e.Use(Trace(tc.opts...))
+			e.GET("/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+			e.POST("/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+			if _, ok := rec.FindOne(tc.want); !ok {
+				t.Fatalf("span %q not found in %d spans", tc.want, len(rec.Spans()))
+			}
+		})
+	}
+}
+
+func TestTraceFilter(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		method string
+		path   string
+		traced bool
+	}{
+		{"default", nil, http.MethodGet, "/healthz", true},
+		{"exclude", []Option{WithExcludePaths("/healthz", "/static/**")}, http.MethodGet, "/healthz", false},
+		{"exclude nested", []Option{WithExcludePaths("/static/**")}, http.MethodGet, "/static/css/a.css", false},
+		{"exclude miss", []Option{WithExcludePaths("/static/**")}, http.MethodGet, "/users/1", true},
+		{"include", []Option{WithIncludePaths("/users/*")}, http.MethodGet, "/users/1", true},
+		{"include miss", []Option{WithIncludePaths("/users/*")}, http.MethodGet, "/healthz", false},
+		{"method", []Option{WithMethods("post")}, http.MethodPost, "/users/1", true},
+		{"method miss", []Option{WithMethods("post")}, http.MethodGet, "/users/1", false},
+		{"filter", []Option{WithFilter(func(c *gin.Context) bool { return c.GetHeader("X-Probe") == "" })}, http.MethodGet, "/users/1", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec.Reset()
+			e := gin.New()
+			e.Use(Trace(tc.opts...))
+			e.Any("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("X-Probe", "lb")
+			e.ServeHTTP(httptest.NewRecorder(), req)
+			if traced := len(rec.Spans()) == 1; traced != tc.traced {
+				t.Fatalf("traced: %v, want %v", traced, tc.traced)
+			}
+		})
+	}
+}
+
+func TestSkippedPropagation(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(Trace(WithExcludePaths("/healthz")))
+	e.GET("/healthz", func(c *gin.Context) {
+		tr, ok := trace.StartSpanFromContext(c.Request.Context(), "downstream")
+		if !ok {
+			t.Fatal("incoming trace not propagated")
+		}
+		tr.Finish(nil)
+		c.Status(http.StatusOK)
+	})
+
+	tracer := rec.Tracer()
+	upstream := tracer.StartSpan("upstream")
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	if err := upstream.Inject(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header)); err != nil {
+		t.Fatal(err)
+	}
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	upstream.Finish(nil)
+
+	if len(rec.Spans()) != 2 {
+		t.Fatalf("spans: %d, want upstream and downstream only", len(rec.Spans()))
+	}
+	parent, _ := rec.FindOne("upstream")
+	child, _ := rec.FindOne("downstream")
+	tracetest.AssertChildOf(t, child, parent)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"go-trace/http"
 	"go-trace/trace"
+	"go-trace/trace/tracelog"
 	xhttp "net/http"
 	"os"
 	"os/signal"
@@ -25,7 +26,7 @@ type resp struct {
 func hello(c *http.Context) {
 	var res resp
 	if err := client.Get(c.Request.Context(), baseURL+"/permit", nil, &res); err == nil {
-		log.Infof("hello request permit, resp:%s", res.Data)
+		log.WithContext(c.Request.Context()).Infof("hello request permit, resp:%s", res.Data)
 	}
 	c.Jsonify("hello")
 }
@@ -33,7 +34,7 @@ func hello(c *http.Context) {
 func permit(c *http.Context) {
 	var res resp
 	if err := client.Get(c.Request.Context(), baseURL+"/verify", nil, &res); err == nil {
-		log.Infof("hello request verify, resp:%s", res.Data)
+		log.WithContext(c.Request.Context()).Infof("hello request verify, resp:%s", res.Data)
 	}
 	c.Jsonify("permit")
 }
@@ -51,22 +52,26 @@ func addRoutes(e *http.Engine) {
 	e.GET("/verify", http.Handle(verify))
 	e.GET("/permit", http.Handle(permit))
 	e.GET("/redirect", http.Handle(redirect))
+	e.MountMetrics("/metrics", nil)
 }
 
 func main() {
 	conf := &http.Config{
-		Addr:    ":8888",
-		Timeout: time.Duration(1),
+		Addr:          ":8888",
+		Timeout:       time.Duration(1),
+		TraceIDHeader: http.DefaultTraceIDHeader,
+		TraceExclude:  []string{"/metrics"},
 	}
 	clientConf := &http.ClientConfig{
 		Dial:      time.Duration(100 * time.Millisecond),
 		Timeout:   time.Duration(300 * time.Millisecond),
 		KeepAlive: time.Duration(60 * time.Second),
 	}
+	log.AddHook(tracelog.NewLogrusHook(true))
 	client = http.NewClient(clientConf)
 	engine := http.NewEngine(conf)
 	addRoutes(engine)
-	http.InitTracer(&trace.Config{
+	if err := http.InitTracer(&trace.Config{
 		ServiceName:        "Trace-test-server",
 		OpenReporter:       true,                           // open jaeger reporter
 		Stdlog:             true,                           // log stdout
@@ -75,13 +80,15 @@ func main() {
 		SamplerParam:       1,                              // 0 or 1
 		FlushInterval:      time.Duration(1 * time.Second), // second, default 1
 		DisableClientTrace: false,                          // open client trace
-	})
+	}); err != nil {
+		log.Fatalf("Init tracer error: %v", err)
+	}
 	http.Start(conf, engine)
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM)
 	for {
 		s := <-quit
-		log.Info("Got a signal: %s", s.String())
+		log.Infof("Got a signal: %s", s.String())
 		switch s {
 		case syscall.SIGQUIT, syscall.SIGINT, syscall.SIGTERM, syscall.SIGSTOP:
 			http.Shutdown()
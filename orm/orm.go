@@ -1,44 +1,122 @@
 package orm
 
 import (
+	"fmt"
+	"log"
+	"os"
 	"time"
 
-	"log"
+	"go-trace/trace"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 )
 
+// Log levels of gorm logger
+const (
+	LogSilent = "silent"
+	LogError  = "error"
+	LogWarn   = "warn"
+	LogInfo   = "info"
+)
+
+var logLevels = map[string]logger.LogLevel{
+	LogSilent: logger.Silent,
+	LogError:  logger.Error,
+	LogWarn:   logger.Warn,
+	LogInfo:   logger.Info,
+}
+
 // Config database
 type Config struct {
-	DSN         string        // database adress
-	Active      int           // pool
-	Idle        int           // pool
-	IdleTimeout time.Duration // conn max life time
+	DSN                string          // database adress
+	Active             int             // pool
+	Idle               int             // pool
+	IdleTimeout        time.Duration   // conn max life time
+	MaxIdleTime        time.Duration   // conn max idle time
+	DisablePrepareStmt bool            // don't cache prepared statements
+	LogLevel           string          // silent, error, warn or info, default warn
+	SlowThreshold      time.Duration   // slow query threshold, logged and tagged slow_query=true on spans
+	Statement          trace.Sanitizer // db.statement of spans, literals are replaced by default
+	PoolStatsTag       bool            // tag statements waiting for a connection with the approximate wait and pool stats
+	PoolMetrics        string          // registers a PoolCollector with this name label to the default prometheus registry, empty disables
+}
+
+// Validate checks pool settings and log level
+func (c *Config) Validate() error {
+	if c.Active < 0 || c.Idle < 0 || c.IdleTimeout < 0 || c.MaxIdleTime < 0 || c.SlowThreshold < 0 {
+		return fmt.Errorf("pool settings must not be negative")
+	}
+	if _, ok := logLevels[c.LogLevel]; !ok && c.LogLevel != "" {
+		return fmt.Errorf("unknown log level %q", c.LogLevel)
+	}
+	return c.Statement.Validate()
+}
+
+// logger returns gorm logger of LogLevel and SlowThreshold
+func (c *Config) logger() logger.Interface {
+	if c.LogLevel == "" && c.SlowThreshold == 0 {
+		return logger.Default
+	}
+	level, ok := logLevels[c.LogLevel]
+	if !ok {
+		level = logger.Warn
+	}
+	return logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold: c.SlowThreshold,
+		LogLevel:      level,
+		Colorful:      true,
+	})
 }
 
 // NewMySQL returns a new MySQL connection
 func NewMySQL(c *Config) (db *gorm.DB) {
-	db, err := gorm.Open(mysql.New(mysql.Config{
+	return Open(DialectMySQL, mysql.New(mysql.Config{
 		DSN:                       c.DSN, // DSN data source name
 		DefaultStringSize:         256,   // string 类型字段的默认长度
 		DisableDatetimePrecision:  true,  // 禁用 datetime 精度，MySQL 5.6 之前的数据库不支持
 		DontSupportRenameIndex:    true,  // 重命名索引时采用删除并新建的方式，MySQL 5.7 之前的数据库和 MariaDB 不支持重命名索引
 		DontSupportRenameColumn:   true,  // 用 `change` 重命名列，MySQL 8 之前的数据库和 MariaDB 不支持重命名列
 		SkipInitializeWithVersion: false, // 根据当前 MySQL 版本自动配置
-	}), &gorm.Config{
+	}), c)
+}
+
+// NewPostgres returns a new PostgreSQL connection, the DSN is a URL or
+// key/value string, e.g. "host=127.0.0.1 user=app dbname=app port=5432"
+func NewPostgres(c *Config) (db *gorm.DB) {
+	return Open(DialectPostgres, postgres.Open(c.DSN), c)
+}
+
+// Open opens dialector of dialect, installs OpentracingPlugin and applies pool settings.
+// Drivers with heavy dependencies use it from their own package, see orm/sqlite and orm/sqlserver.
+func Open(dialect string, dialector gorm.Dialector, c *Config) (db *gorm.DB) {
+	db, err := gorm.Open(dialector, &gorm.Config{
 		NamingStrategy: schema.NamingStrategy{SingularTable: true},
-		PrepareStmt:    true,
+		PrepareStmt:    !c.DisablePrepareStmt,
+		Logger:         c.logger(),
 	})
 	if err != nil {
 		log.Printf("open database error:(%v)", err)
 		panic(err)
 	}
-	db.Use(&OpentracingPlugin{})
+	info := parseDSN(dialect, c.DSN)
+	db.Use(&OpentracingPlugin{
+		Statement:     c.Statement,
+		SlowThreshold: c.SlowThreshold,
+		PoolStatsTag:  c.PoolStatsTag,
+		System:        dialect,
+		Instance:      info.instance,
+		User:          info.user,
+		Addr:          info.addr,
+	})
 	conn, _ := db.DB()
 	conn.SetMaxIdleConns(c.Idle)
 	conn.SetMaxOpenConns(c.Active)
-	// conn.SetConnMaxLifetime(time.Duration(c.IdleTimeout) / time.Second)
+	conn.SetConnMaxLifetime(c.IdleTimeout)
+	conn.SetConnMaxIdleTime(c.MaxIdleTime)
+	registerPool(c.PoolMetrics, conn)
 	return
 }
@@ -3,28 +3,22 @@ package orm
 import (
 	"context"
 	"go-trace/trace"
-	"io"
+	"go-trace/trace/tracetest"
+	"net"
 	"testing"
 	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
 )
 
 var (
-	closer io.Closer
-	tr     trace.Tracer
+	rec *tracetest.Recorder
+	tr  trace.Tracer
 )
 
 func init() {
-	conf := &trace.Config{
-		ServiceName:        "Trace-database-service",
-		OpenReporter:       true,                           // open jaeger reporter
-		Stdlog:             true,                           // log stdout
-		ReportHost:         "127.0.0.1:6831",               // host:port -> 127.0.0.1:6831
-		SamplerType:        "const",                        //const, probabilistic, rateLimiting, or remote
-		SamplerParam:       1,                              // 0 or 1
-		FlushInterval:      time.Duration(1 * time.Second), // second, default 1
-		DisableClientTrace: false,
-	}
-	_, closer = trace.NewTracer(conf)
+	rec = tracetest.NewRecorder().Install()
 	tr = trace.StartSpan("gorm-trace")
 }
 
@@ -35,8 +29,12 @@ type User struct {
 }
 
 func TestMySQL(t *testing.T) {
-	ctx := context.WithValue(context.Background(), trace.CtxKey, tr.GetSpan())
-	defer closer.Close()
+	cn, err := net.DialTimeout("tcp", "127.0.0.1:3306", 100*time.Millisecond)
+	if err != nil {
+		t.Skipf("mysql is not reachable: %v", err)
+	}
+	cn.Close()
+	ctx := tr.ContextWithSpan(context.Background())
 	conf := &Config{
 		DSN:    "root:@tcp(127.0.0.1:3306)/mysql?charset=utf8&parseTime=True&loc=Local",
 		Idle:   5,
@@ -51,4 +49,72 @@ func TestMySQL(t *testing.T) {
 	for _, user := range users {
 		t.Logf("user: %+v", user)
 	}
+	tr.Finish(nil)
+
+	parent, _ := rec.FindOne("gorm-trace")
+	span, ok := rec.FindOne("gorm:query user")
+	if !ok {
+		t.Fatalf("gorm span not recorded")
+	}
+	tracetest.AssertChildOf(t, span, parent)
+	tracetest.AssertTag(t, span, trace.TagDBType, "sql")
+}
+
+func TestSpanNaming(t *testing.T) {
+	rec.Reset()
+	root := trace.StartSpan("naming")
+	dsn := "reader:secret@tcp(10.0.0.1:3306)/shop?parseTime=True"
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: dsn, SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Use(&OpentracingPlugin{Instance: "shop", User: "reader", Addr: "10.0.0.1:3306"})
+	db = db.WithContext(root.ContextWithSpan(context.Background()))
+	db.Where("name = ?", "bob").Find(&[]User{})
+	db.Delete(&User{}, "host = 'localhost'")
+	root.Finish(nil)
+
+	parent, _ := rec.FindOne("naming")
+	query, _ := rec.FindOne("gorm:query users")
+	tracetest.AssertChildOf(t, query, parent)
+	tracetest.AssertTag(t, query, tagDBOperation, OperationQuery)
+	tracetest.AssertTag(t, query, trace.TagDBInstance, "shop")
+	tracetest.AssertTag(t, query, trace.TagDBUser, "reader")
+	tracetest.AssertTag(t, query, trace.TagPeerAddress, "10.0.0.1:3306")
+	tracetest.AssertTag(t, query, tagDBRowsAffected, int64(0))
+	del, _ := rec.FindOne("gorm:delete users")
+	tracetest.AssertTag(t, del, trace.TagDBStatement, "DELETE FROM `users` WHERE host = ?")
+}
+
+func TestParseDSN(t *testing.T) {
+	cases := []struct {
+		dialect string
+		dsn     string
+		want    dsnInfo
+	}{
+		{DialectMySQL, "root:pass@tcp(127.0.0.1:3306)/shop?charset=utf8", dsnInfo{"shop", "root", "127.0.0.1:3306"}},
+		{DialectPostgres, "host=10.0.0.2 user=app password=x dbname=orders port=5433 sslmode=disable", dsnInfo{"orders", "app", "10.0.0.2:5433"}},
+		{DialectPostgres, "postgres://app:x@10.0.0.2/orders?sslmode=disable", dsnInfo{"orders", "app", "10.0.0.2:5432"}},
+		{DialectSQLite, "file:test.db?cache=shared", dsnInfo{instance: "test.db"}},
+		{DialectSQLServer, "sqlserver://sa:x@10.0.0.3:1433?database=crm", dsnInfo{"crm", "sa", "10.0.0.3:1433"}},
+		{DialectSQLServer, "server=10.0.0.3,1434;user id=sa;password=x;database=crm", dsnInfo{"crm", "sa", "10.0.0.3:1434"}},
+	}
+	for _, c := range cases {
+		if got := parseDSN(c.dialect, c.dsn); got != c.want {
+			t.Fatalf("parseDSN(%s, %q) = %+v, want %+v", c.dialect, c.dsn, got, c.want)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := (&Config{LogLevel: LogSilent, SlowThreshold: time.Second}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Config{LogLevel: "debug"}).Validate(); err == nil {
+		t.Fatal("unknown log level should be invalid")
+	}
 }
@@ -0,0 +1,14 @@
+// Package sqlite opens traced SQLite connections, the driver requires cgo.
+package sqlite
+
+import (
+	"go-trace/orm"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// New returns a new SQLite connection, the DSN is a file path or ":memory:"
+func New(c *orm.Config) (db *gorm.DB) {
+	return orm.Open(orm.DialectSQLite, sqlite.Open(c.DSN), c)
+}
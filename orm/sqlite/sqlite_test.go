@@ -0,0 +1,217 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-trace/orm"
+	"go-trace/trace"
+	"go-trace/trace/tracetest"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm"
+)
+
+// User .
+type User struct {
+	Host string `gorm:"Host"`
+	User string `gorm:"User"`
+}
+
+func TestNew(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	root := trace.StartSpan("sqlite")
+	conn := New(&orm.Config{DSN: ":memory:", Idle: 1, Active: 1})
+	db, _ := conn.DB()
+	defer db.Close()
+	if err := conn.WithContext(root.ContextWithSpan(context.Background())).Exec("SELECT 1").Error; err != nil {
+		t.Fatal(err)
+	}
+	root.Finish(nil)
+
+	span, ok := rec.FindOne("gorm:raw")
+	if !ok {
+		t.Fatal("gorm span not recorded")
+	}
+	tracetest.AssertTag(t, span, trace.TagDBType, "sql")
+	tracetest.AssertTag(t, span, "db.system", orm.DialectSQLite)
+}
+
+func TestSpans(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	root := trace.StartSpan("sqlite")
+	conn := New(&orm.Config{DSN: "file::memory:?cache=shared", Idle: 1, Active: 1})
+	db, _ := conn.DB()
+	defer db.Close()
+	if err := conn.AutoMigrate(&User{}); err != nil {
+		t.Fatal(err)
+	}
+	conn = conn.WithContext(root.ContextWithSpan(context.Background()))
+	if err := conn.Create(&User{Host: "localhost", User: "root"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	users := []User{}
+	if err := conn.Where("host = ?", "localhost").Find(&users).Error; err != nil || len(users) != 1 {
+		t.Fatalf("find users %v, error: %v", users, err)
+	}
+	err := conn.Take(&User{}, "host = ?", "missing").Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("take missing user error: %v", err)
+	}
+	// callers returning gorm.ErrRecordNotFound are still failed
+	root.Finish(&err)
+
+	parent, _ := rec.FindOne("sqlite")
+	tracetest.AssertTag(t, parent, trace.TagError, true)
+	// create runs in the default transaction of gorm
+	tx, _ := rec.FindOne("gorm:transaction")
+	tracetest.AssertChildOf(t, tx, parent)
+	tracetest.AssertTag(t, tx, "db.transaction.outcome", orm.TxCommit)
+	queries := rec.FindByOperation("gorm:query user")
+	if len(queries) != 2 || queries[1].Tag(trace.TagError) != nil {
+		t.Fatalf("record not found should not fail the query span: %v", queries)
+	}
+	for name, want := range map[string]*tracetest.Span{"gorm:create user": tx, "gorm:query user": parent} {
+		span := rec.FindByOperation(name)[0]
+		tracetest.AssertChildOf(t, span, want)
+		tracetest.AssertTag(t, span, trace.TagDBType, "sql")
+		tracetest.AssertTag(t, span, "db.system", orm.DialectSQLite)
+		tracetest.AssertTag(t, span, trace.TagDBInstance, ":memory:")
+		tracetest.AssertTag(t, span, "db.rows_affected", int64(1))
+	}
+}
+
+func TestSlowQuery(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	root := trace.StartSpan("slow")
+	conf := &orm.Config{DSN: ":memory:", Active: 1, DisablePrepareStmt: true, LogLevel: orm.LogSilent, SlowThreshold: time.Nanosecond}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	conn := New(conf)
+	db, _ := conn.DB()
+	defer db.Close()
+	conn.WithContext(root.ContextWithSpan(context.Background())).Exec("SELECT 1")
+	root.Finish(nil)
+
+	span, _ := rec.FindOne("gorm:raw")
+	tracetest.AssertTag(t, span, "slow_query", true)
+}
+
+func TestTransaction(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	root := trace.StartSpan("transaction")
+	conn := New(&orm.Config{DSN: "file:tx?mode=memory&cache=shared", Idle: 1, Active: 1, PoolStatsTag: true})
+	db, _ := conn.DB()
+	defer db.Close()
+	conn.AutoMigrate(&User{})
+	conn = conn.WithContext(root.ContextWithSpan(context.Background()))
+
+	errAbort := errors.New("abort")
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&User{Host: "h1", User: "u1"}).Error; err != nil {
+			return err
+		}
+		// the pool has one connection, it's held by the transaction
+		go conn.Where("host = ?", "h1").Find(&[]User{})
+		time.Sleep(10 * time.Millisecond)
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("transaction error %v, want %v", err, errAbort)
+	}
+	conn.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&User{Host: "h2", User: "u2"}).Error
+	}, &sql.TxOptions{})
+	root.Finish(nil)
+	waitQuery := time.Now().Add(time.Second)
+	for len(rec.FindByOperation("gorm:query user")) == 0 && time.Now().Before(waitQuery) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	parent, _ := rec.FindOne("transaction")
+	txs := rec.FindByOperation("gorm:transaction")
+	if len(txs) != 2 {
+		t.Fatalf("recorded %d transactions, want 2", len(txs))
+	}
+	for i, outcome := range []string{orm.TxRollback, orm.TxCommit} {
+		tracetest.AssertChildOf(t, txs[i], parent)
+		tracetest.AssertTag(t, txs[i], "db.transaction.outcome", outcome)
+		if children := rec.Children(txs[i]); len(children) != 1 || children[0].OperationName != "gorm:create user" {
+			t.Fatalf("transaction %s children %v", outcome, children)
+		}
+	}
+	query, _ := rec.FindOne("gorm:query user")
+	tracetest.AssertChildOf(t, query, parent)
+	if query.Tag("db.pool.wait_count_approx") == nil {
+		t.Fatalf("pool wait not tagged: %v", query.Tags())
+	}
+}
+
+func TestPoolCollector(t *testing.T) {
+	conn := New(&orm.Config{DSN: ":memory:", Idle: 1, Active: 3})
+	db, _ := conn.DB()
+	defer db.Close()
+	conn.Exec("SELECT 1")
+	want := `
+# HELP sql_pool_connections Number of connections in the pool by state: open, idle or in_use.
+# TYPE sql_pool_connections gauge
+sql_pool_connections{name="orders",state="idle"} 1
+sql_pool_connections{name="orders",state="in_use"} 0
+sql_pool_connections{name="orders",state="open"} 1
+# HELP sql_pool_max_open_connections Maximum number of open connections.
+# TYPE sql_pool_max_open_connections gauge
+sql_pool_max_open_connections{name="orders"} 3
+`
+	c := orm.NewPoolCollector("orders", db)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "sql_pool_connections", "sql_pool_max_open_connections"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPoolMetrics(t *testing.T) {
+	for _, name := range []string{"users", "orders"} {
+		conn := New(&orm.Config{DSN: ":memory:", PoolMetrics: name})
+		db, _ := conn.DB()
+		defer db.Close()
+	}
+	if n, err := testutil.GatherAndCount(prometheus.DefaultGatherer, "sql_pool_max_open_connections"); err != nil || n != 2 {
+		t.Fatalf("gathered %d metrics, error: %v", n, err)
+	}
+}
+
+func TestSessionPrepareStmt(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	root := trace.StartSpan("session")
+	conn := New(&orm.Config{DSN: ":memory:", Idle: 1, Active: 1, DisablePrepareStmt: true})
+	db, _ := conn.DB()
+	defer db.Close()
+	if err := conn.AutoMigrate(&User{}); err != nil {
+		t.Fatal(err)
+	}
+	session := conn.WithContext(root.ContextWithSpan(context.Background())).Session(&gorm.Session{PrepareStmt: true})
+	err := session.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&User{Host: "h1", User: "u1"}).Error
+	})
+	if err != nil {
+		t.Fatalf("prepared statement transaction: %v", err)
+	}
+	root.Finish(nil)
+
+	span, ok := rec.FindOne("gorm:create user")
+	if !ok {
+		t.Fatal("gorm span not recorded")
+	}
+	parent, _ := rec.FindOne("session")
+	tracetest.AssertChildOf(t, span, parent)
+}
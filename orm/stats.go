@@ -0,0 +1,82 @@
+package orm
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	tagPoolOpen  = "db.pool.open"
+	tagPoolInUse = "db.pool.in_use"
+	tagPoolIdle  = "db.pool.idle"
+)
+
+// PoolCollector publishes sql.DBStats as prometheus metrics, stats are read on every scrape.
+//
+//	conn, _ := db.DB()
+//	prometheus.MustRegister(orm.NewPoolCollector("orders", conn))
+//
+// Set Config.PoolMetrics to register it by the constructors.
+type PoolCollector struct {
+	db          *sql.DB
+	conns       *prometheus.Desc
+	maxOpen     *prometheus.Desc
+	wait        *prometheus.Desc
+	waitSeconds *prometheus.Desc
+	closed      *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*PoolCollector)(nil)
+
+// NewPoolCollector returns a PoolCollector, name is the value of the name label
+func NewPoolCollector(name string, db *sql.DB) *PoolCollector {
+	labels := prometheus.Labels{"name": name}
+	return &PoolCollector{
+		db: db,
+		conns: prometheus.NewDesc("sql_pool_connections",
+			"Number of connections in the pool by state: open, idle or in_use.", []string{"state"}, labels),
+		maxOpen: prometheus.NewDesc("sql_pool_max_open_connections",
+			"Maximum number of open connections.", nil, labels),
+		wait: prometheus.NewDesc("sql_pool_wait_total",
+			"Number of connections waited for.", nil, labels),
+		waitSeconds: prometheus.NewDesc("sql_pool_wait_seconds_total",
+			"Total time blocked waiting for a new connection.", nil, labels),
+		closed: prometheus.NewDesc("sql_pool_closed_total",
+			"Number of connections closed by reason: max_idle, max_idle_time or max_lifetime.", []string{"reason"}, labels),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.conns
+	ch <- c.maxOpen
+	ch <- c.wait
+	ch <- c.waitSeconds
+	ch <- c.closed
+}
+
+// Collect implements prometheus.Collector
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(s.OpenConnections), "open")
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(s.Idle), "idle")
+	ch <- prometheus.MustNewConstMetric(c.conns, prometheus.GaugeValue, float64(s.InUse), "in_use")
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.wait, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitSeconds, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.closed, prometheus.CounterValue, float64(s.MaxIdleClosed), "max_idle")
+	ch <- prometheus.MustNewConstMetric(c.closed, prometheus.CounterValue, float64(s.MaxIdleTimeClosed), "max_idle_time")
+	ch <- prometheus.MustNewConstMetric(c.closed, prometheus.CounterValue, float64(s.MaxLifetimeClosed), "max_lifetime")
+}
+
+// registerPool registers the PoolCollector of db to the default prometheus registry if name is set
+func registerPool(name string, db *sql.DB) {
+	if name == "" {
+		return
+	}
+	if err := prometheus.Register(NewPoolCollector(name, db)); err != nil {
+		log.Printf("orm: register pool metrics %q error: %v", name, err)
+	}
+}
@@ -1,51 +1,135 @@
 package orm
 
 import (
+	"database/sql"
+	"errors"
 	"go-trace/trace"
-	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
 const (
 	gormSpanKey        = "__gorm_span"
+	gormStartKey       = "__gorm_start"
+	gormPoolWaitKey    = "__gorm_pool_wait"
 	callBackBeforeName = "opentracing:before"
 	callBackAfterName  = "opentracing:after"
+
+	tagDBSystem       = "db.system"
+	tagDBOperation    = "db.operation"
+	tagDBRowsAffected = "db.rows_affected"
+	tagSlowQuery      = "slow_query"
 )
 
-func before(db *gorm.DB) {
-	tr, ok := trace.StartSpanFromContextV2(db.Statement.Context, "gorm")
-	if !ok {
-		return
-	}
+// Operation types of gorm callbacks
+const (
+	OperationCreate = "create"
+	OperationQuery  = "query"
+	OperationUpdate = "update"
+	OperationDelete = "delete"
+	OperationRow    = "row"
+	OperationRaw    = "raw"
+)
+
+// setTags sets the static tags of operation
+func (op *OpentracingPlugin) setTags(tr *trace.Tracer, operation string) {
 	tr.SetTag(trace.Tag(trace.TagPeerService, "database"))
 	tr.SetTag(trace.Tag(trace.TagSpanKind, "client"))
 	tr.SetTag(trace.Tag(trace.TagComponent, "db/gorm"))
-	tr.SetTag(trace.Tag(trace.TagDBType, "sql"))
-	db.InstanceSet(gormSpanKey, tr)
+	dbType := op.DBType
+	if dbType == "" {
+		dbType = "sql"
+	}
+	tr.SetTag(trace.Tag(trace.TagDBType, dbType))
+	if op.System != "" {
+		tr.SetTag(trace.Tag(tagDBSystem, op.System))
+	}
+	tr.SetTag(trace.Tag(tagDBOperation, operation))
+	if op.Instance != "" {
+		tr.SetTag(trace.Tag(trace.TagDBInstance, op.Instance))
+	}
+	if op.User != "" {
+		tr.SetTag(trace.Tag(trace.TagDBUser, op.User))
+	}
+	if op.Addr != "" {
+		tr.SetTag(trace.Tag(trace.TagPeerAddress, op.Addr))
+	}
 }
 
-func after(db *gorm.DB) {
-	val, ok := db.InstanceGet(gormSpanKey)
-	if !ok {
-		return
+// before returns the callback that starts the span of operation,
+// statements of a traced transaction are children of the transaction span.
+func (op *OpentracingPlugin) before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		tr, ok := trace.StartSpanFromContext(parentContext(db), "gorm:"+operation)
+		if !ok {
+			return
+		}
+		op.setTags(&tr, operation)
+		db.InstanceSet(gormSpanKey, tr)
+		db.InstanceSet(gormStartKey, time.Now())
+		db.InstanceSet(gormPoolWaitKey, op.poolWait())
 	}
-	tr, ok := val.(trace.Tracer)
-	if !ok {
-		return
+}
+
+// after returns the callback that finishes the span of operation
+func (op *OpentracingPlugin) after(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		val, ok := db.InstanceGet(gormSpanKey)
+		if !ok {
+			return
+		}
+		tr, ok := val.(trace.Tracer)
+		if !ok {
+			return
+		}
+		// the table is known after gorm parsed the statement
+		if db.Statement.Table != "" {
+			tr.SetTitle("gorm:" + operation + " " + db.Statement.Table)
+		}
+		tr.SetTag(trace.Tag(trace.TagDBStatement, op.statement(db)))
+		tr.SetTag(trace.Tag(tagDBRowsAffected, db.RowsAffected))
+		if wait, ok := db.InstanceGet(gormPoolWaitKey); ok {
+			wait.(func(*trace.Tracer))(&tr)
+		}
+		if start, ok := db.InstanceGet(gormStartKey); ok && op.SlowThreshold > 0 && time.Since(start.(time.Time)) >= op.SlowThreshold {
+			tr.SetTag(trace.Tag(tagSlowQuery, true))
+		}
+		// gorm.ErrRecordNotFound is an empty result of First, Take or Last, not a failed statement.
+		// it's not registered by trace.IgnoreErrors so spans of callers returning it still fail.
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		tr.Finish(&err)
 	}
-	tr.SetTag(trace.Tag(trace.TagDBStatement, strings.ToLower(db.Statement.SQL.String())))
+}
 
-	if db.Error != nil {
-		tr.SetLog(trace.LogBool(trace.TagError, true), trace.LogString(trace.LogMessage, db.Error.Error()))
-		tr.Finish(&db.Error)
-		return
+// statement returns the sanitized sql, values are only bound in full mode
+func (op *OpentracingPlugin) statement(db *gorm.DB) string {
+	query := db.Statement.SQL.String()
+	if op.Statement.Mode == trace.StatementFull && len(db.Statement.Vars) > 0 {
+		query = db.Dialector.Explain(query, db.Statement.Vars...)
 	}
-	tr.Finish(nil)
+	if db.Dialector.Name() == "mysql" {
+		return op.Statement.MySQL(query)
+	}
+	return op.Statement.SQL(query)
 }
 
 // OpentracingPlugin .
-type OpentracingPlugin struct{}
+type OpentracingPlugin struct {
+	Statement     trace.Sanitizer
+	SlowThreshold time.Duration // queries slower than it are tagged slow_query=true, 0 disables
+	DBType        string        // db.type, default sql
+	System        string        // db.system, the dialect e.g. mysql or postgresql
+	Instance      string        // db.instance, database name
+	User          string        // db.user
+	Addr          string        // peer.address, database host:port
+	PoolStatsTag  bool          // tag statements waiting for a connection with the approximate wait and pool stats
+
+	sqlDB *sql.DB
+}
 
 // Name returns the name of the plugin
 func (op *OpentracingPlugin) Name() string {
@@ -54,20 +138,25 @@ func (op *OpentracingPlugin) Name() string {
 
 // Initialize init OpentracingPlugin
 func (op *OpentracingPlugin) Initialize(db *gorm.DB) (err error) {
+	// trace transactions, only the statement pool is wrapped and new sessions inherit it.
+	// db.Config.ConnPool is kept, gorm wraps it for Session{PrepareStmt: true}.
+	op.sqlDB, _ = db.DB()
+	db.Statement.ConnPool = &tracedPool{ConnPool: db.Statement.ConnPool, op: op}
+
 	// start before
-	db.Callback().Create().Before("gorm:before_create").Register(callBackBeforeName, before)
-	db.Callback().Query().Before("gorm:query").Register(callBackBeforeName, before)
-	db.Callback().Delete().Before("gorm:before_delete").Register(callBackBeforeName, before)
-	db.Callback().Update().Before("gorm:setup_reflect_value").Register(callBackBeforeName, before)
-	db.Callback().Row().Before("gorm:row").Register(callBackBeforeName, before)
-	db.Callback().Raw().Before("gorm:raw").Register(callBackBeforeName, before)
+	db.Callback().Create().Before("gorm:before_create").Register(callBackBeforeName, op.before(OperationCreate))
+	db.Callback().Query().Before("gorm:query").Register(callBackBeforeName, op.before(OperationQuery))
+	db.Callback().Delete().Before("gorm:before_delete").Register(callBackBeforeName, op.before(OperationDelete))
+	db.Callback().Update().Before("gorm:setup_reflect_value").Register(callBackBeforeName, op.before(OperationUpdate))
+	db.Callback().Row().Before("gorm:row").Register(callBackBeforeName, op.before(OperationRow))
+	db.Callback().Raw().Before("gorm:raw").Register(callBackBeforeName, op.before(OperationRaw))
 
 	// finish after
-	db.Callback().Create().After("gorm:after_create").Register(callBackAfterName, after)
-	db.Callback().Query().After("gorm:after_query").Register(callBackAfterName, after)
-	db.Callback().Delete().After("gorm:after_delete").Register(callBackAfterName, after)
-	db.Callback().Update().After("gorm:after_update").Register(callBackAfterName, after)
-	db.Callback().Row().After("gorm:row").Register(callBackAfterName, after)
-	db.Callback().Raw().After("gorm:raw").Register(callBackAfterName, after)
+	db.Callback().Create().After("gorm:after_create").Register(callBackAfterName, op.after(OperationCreate))
+	db.Callback().Query().After("gorm:after_query").Register(callBackAfterName, op.after(OperationQuery))
+	db.Callback().Delete().After("gorm:after_delete").Register(callBackAfterName, op.after(OperationDelete))
+	db.Callback().Update().After("gorm:after_update").Register(callBackAfterName, op.after(OperationUpdate))
+	db.Callback().Row().After("gorm:row").Register(callBackAfterName, op.after(OperationRow))
+	db.Callback().Raw().After("gorm:raw").Register(callBackAfterName, op.after(OperationRaw))
 	return
 }
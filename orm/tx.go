@@ -0,0 +1,139 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"go-trace/trace"
+
+	"gorm.io/gorm"
+)
+
+const (
+	tagTxOutcome = "db.transaction.outcome"
+	// sql.DBStats are pool-wide, waits of concurrent statements are included
+	tagPoolWait      = "db.pool.wait_approx"
+	tagPoolWaitCount = "db.pool.wait_count_approx"
+)
+
+// Outcomes of traced transactions
+const (
+	TxCommit   = "commit"
+	TxRollback = "rollback"
+)
+
+// tracedPool wraps gorm.ConnPool, every transaction begun by it has a span
+// that the statements of the transaction nest under.
+type tracedPool struct {
+	gorm.ConnPool
+	op *OpentracingPlugin
+}
+
+var (
+	_ gorm.ConnPoolBeginner = (*tracedPool)(nil)
+	_ gorm.GetDBConnector   = (*tracedPool)(nil)
+)
+
+// GetDBConn returns the *sql.DB of the wrapped pool, used by gorm.DB.DB
+func (p *tracedPool) GetDBConn() (*sql.DB, error) {
+	switch pool := p.ConnPool.(type) {
+	case gorm.GetDBConnector:
+		return pool.GetDBConn()
+	case *sql.DB:
+		return pool, nil
+	}
+	return nil, gorm.ErrInvalidDB
+}
+
+// BeginTx starts the transaction span if ctx contains a parent span
+func (p *tracedPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tr, traced := trace.StartSpanFromContext(ctx, "gorm:transaction")
+	if traced {
+		p.op.setTags(&tr, "transaction")
+	}
+	wait := p.op.poolWait()
+	var (
+		tx  gorm.ConnPool
+		err error
+	)
+	switch pool := p.ConnPool.(type) {
+	case gorm.TxBeginner:
+		tx, err = pool.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		tx, err = pool.BeginTx(ctx, opts)
+	default:
+		err = gorm.ErrInvalidTransaction
+	}
+	if !traced {
+		return tx, err
+	}
+	wait(&tr)
+	if err != nil {
+		tr.Finish(&err)
+		return nil, err
+	}
+	return &tracedTx{ConnPool: tx, tr: tr, ctx: tr.ContextWithSpan(ctx)}, nil
+}
+
+// tracedTx is a transaction begun by tracedPool
+type tracedTx struct {
+	gorm.ConnPool
+	tr   trace.Tracer
+	ctx  context.Context
+	once sync.Once
+}
+
+var _ gorm.TxCommitter = (*tracedTx)(nil)
+
+// Commit commits the transaction and finishes its span
+func (tx *tracedTx) Commit() error {
+	err := tx.ConnPool.(gorm.TxCommitter).Commit()
+	tx.finish(TxCommit, err)
+	return err
+}
+
+// Rollback rollbacks the transaction and finishes its span
+func (tx *tracedTx) Rollback() error {
+	err := tx.ConnPool.(gorm.TxCommitter).Rollback()
+	tx.finish(TxRollback, err)
+	return err
+}
+
+// finish finishes the span once, gorm rollbacks a transaction after a failed commit
+func (tx *tracedTx) finish(outcome string, err error) {
+	tx.once.Do(func() {
+		tx.tr.SetTag(trace.Tag(tagTxOutcome, outcome))
+		tx.tr.Finish(&err)
+	})
+}
+
+// parentContext returns the context of the transaction span if db runs in a traced transaction
+func parentContext(db *gorm.DB) context.Context {
+	if tx, ok := db.Statement.ConnPool.(*tracedTx); ok {
+		return tx.ctx
+	}
+	return db.Statement.Context
+}
+
+// poolWait returns a func that tags the time waited for pooled connections since poolWait is called,
+// if PoolStatsTag is set. sql.DBStats are pool-wide, the tags are approximate under concurrency.
+func (op *OpentracingPlugin) poolWait() func(tr *trace.Tracer) {
+	if op.sqlDB == nil || !op.PoolStatsTag {
+		return func(*trace.Tracer) {}
+	}
+	start := op.sqlDB.Stats()
+	return func(tr *trace.Tracer) {
+		end := op.sqlDB.Stats()
+		if count := end.WaitCount - start.WaitCount; count > 0 {
+			tr.SetTag(trace.Tag(tagPoolWait, (end.WaitDuration - start.WaitDuration).Round(time.Microsecond).String()))
+			tr.SetTag(trace.Tag(tagPoolWaitCount, count))
+			tr.SetTag(
+				trace.Tag(tagPoolOpen, end.OpenConnections),
+				trace.Tag(tagPoolInUse, end.InUse),
+				trace.Tag(tagPoolIdle, end.Idle),
+			)
+		}
+	}
+}
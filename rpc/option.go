@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"google.golang.org/grpc/codes"
+)
+
+// DefaultErrorCodes status codes which mark the span failed by default,
+// client faults such as NotFound, InvalidArgument and Canceled are not errors.
+var DefaultErrorCodes = []codes.Code{
+	codes.Unknown,
+	codes.DeadlineExceeded,
+	codes.Unimplemented,
+	codes.Internal,
+	codes.Unavailable,
+	codes.DataLoss,
+}
+
+// Option interceptor option
+type Option func(*options)
+
+type options struct {
+	messageEvents bool
+	errorCodes    map[codes.Code]bool
+}
+
+// WithMessageEvents log every SendMsg/RecvMsg event with the message size, stream interceptors only
+func WithMessageEvents() Option {
+	return func(o *options) {
+		o.messageEvents = true
+	}
+}
+
+// WithErrorCodes set status codes which mark the span failed, default DefaultErrorCodes
+func WithErrorCodes(errorCodes ...codes.Code) Option {
+	return func(o *options) {
+		o.errorCodes = make(map[codes.Code]bool, len(errorCodes))
+		for _, code := range errorCodes {
+			o.errorCodes[code] = true
+		}
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := new(options)
+	WithErrorCodes(DefaultErrorCodes...)(o)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"io"
+	"strings"
+
+	"go-trace/trace"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// splitMethod split "/package.Service/Method" to service and method
+func splitMethod(fullMethod string) (service, method string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "", fullMethod
+}
+
+func setMethodTags(tr *trace.Tracer, fullMethod string) {
+	service, method := splitMethod(fullMethod)
+	tr.SetTag(
+		trace.Tag(trace.TagComponent, "gRPC"),
+		trace.Tag(trace.TagRPCSystem, "grpc"),
+		trace.Tag(trace.TagRPCService, service),
+		trace.Tag(trace.TagRPCMethod, method),
+	)
+}
+
+// finishWithStatus tag the status code of err and finish the span,
+// the span is marked failed only if the code is one of the error codes.
+func finishWithStatus(tr *trace.Tracer, err error, o *options) {
+	if err == io.EOF {
+		err = nil
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		// context.Canceled and context.DeadlineExceeded
+		s = status.FromContextError(err)
+	}
+	tr.SetTag(trace.Tag(trace.TagGRPCStatusCode, int(s.Code())))
+	if s.Code() != codes.OK {
+		if o.errorCodes[s.Code()] {
+			tr.SetError(err)
+		}
+		fields := trace.LogFields(trace.LogString(trace.LogEvent, "grpc.status"))
+		fields = append(fields,
+			trace.LogString("grpc.code", s.Code().String()),
+			trace.LogString(trace.LogMessage, s.Message()),
+		)
+		for _, detail := range s.Details() {
+			fields = append(fields, trace.LogObject("grpc.detail", detail))
+		}
+		tr.SetLog(fields...)
+	}
+	tr.Finish(nil)
+}
@@ -0,0 +1,215 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"go-trace/trace"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	tagStreamSent     = "grpc.stream.sent"
+	tagStreamReceived = "grpc.stream.received"
+	tagClientStream   = "grpc.stream.client_stream"
+	tagServerStream   = "grpc.stream.server_stream"
+)
+
+// streamTracer counts the messages of a stream and finishes its span once
+type streamTracer struct {
+	sync.Mutex
+	tr       trace.Tracer
+	opts     *options
+	sent     int
+	received int
+	once     sync.Once
+	done     chan struct{}
+}
+
+func newStreamTracer(tr trace.Tracer, opts *options) *streamTracer {
+	return &streamTracer{tr: tr, opts: opts, done: make(chan struct{})}
+}
+
+func (s *streamTracer) message(event string, msg interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	var count int
+	if event == "SendMsg" {
+		s.sent++
+		count = s.sent
+	} else {
+		s.received++
+		count = s.received
+	}
+	if !s.opts.messageEvents {
+		return
+	}
+	fields := trace.LogFields(trace.LogString(trace.LogEvent, event))
+	fields = append(fields, trace.LogInt("message.id", count))
+	if m, ok := msg.(proto.Message); ok {
+		fields = append(fields, trace.LogInt("message.size", proto.Size(m)))
+	}
+	s.tr.SetLog(fields...)
+}
+
+// finish the span, io.EOF is the normal end of stream
+func (s *streamTracer) finish(err error) {
+	s.once.Do(func() {
+		s.Lock()
+		s.tr.SetTag(trace.Tag(tagStreamSent, s.sent), trace.Tag(tagStreamReceived, s.received))
+		s.Unlock()
+		finishWithStatus(&s.tr, err, s.opts)
+		close(s.done)
+	})
+}
+
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	st  *streamTracer
+}
+
+// Context returns the context with span
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg .
+func (s *tracedServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.st.message("SendMsg", m)
+	}
+	return err
+}
+
+// RecvMsg .
+func (s *tracedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.st.message("RecvMsg", m)
+	}
+	return err
+}
+
+// OpentracingStreamServerInterceptor rewrite server's stream interceptor with open tracing,
+// one span covers the lifetime of the stream.
+func OpentracingStreamServerInterceptor(t trace.Tracer, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx := ss.Context()
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			md = metadata.New(nil)
+		}
+		var tr trace.Tracer
+		spanCtx, err := t.Extract(opentracing.TextMap, MDReaderWriter{md})
+		if err != nil {
+			tr = t.StartSpan(info.FullMethod, ext.SpanKindRPCServer)
+		} else {
+			tr = t.StartSpan(info.FullMethod, ext.RPCServerOption(spanCtx))
+		}
+		setMethodTags(&tr, info.FullMethod)
+		tr.SetTag(trace.Tag(tagClientStream, info.IsClientStream), trace.Tag(tagServerStream, info.IsServerStream))
+		st := newStreamTracer(tr, o)
+		ctx = tr.ContextWithSpan(ctx)
+		err = handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx, st: st})
+		st.finish(err)
+		return err
+	}
+}
+
+type tracedClientStream struct {
+	grpc.ClientStream
+	desc *grpc.StreamDesc
+	st   *streamTracer
+}
+
+// Header .
+func (s *tracedClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.st.finish(err)
+	}
+	return md, err
+}
+
+// CloseSend finishes the span of a stream without server streaming,
+// the caller may never receive the single response.
+func (s *tracedClientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil || !s.desc.ServerStreams {
+		s.st.finish(err)
+	}
+	return err
+}
+
+// SendMsg io.EOF means the stream is ended, the status is returned by RecvMsg
+func (s *tracedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.st.message("SendMsg", m)
+	} else if err != io.EOF {
+		s.st.finish(err)
+	}
+	return err
+}
+
+// RecvMsg .
+func (s *tracedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.st.finish(err)
+		return err
+	}
+	s.st.message("RecvMsg", m)
+	if !s.desc.ServerStreams {
+		// client streaming call returns one response
+		s.st.finish(nil)
+	}
+	return nil
+}
+
+// OpentracingStreamClientInterceptor rewrite client's stream interceptor with open tracing,
+// the span is finished when the stream ends with io.EOF, an error or the context is canceled,
+// or by CloseSend if the server doesn't stream. Like grpc.ClientConn.NewStream, callers must
+// drain the stream until RecvMsg returns an error or cancel the context, otherwise the span
+// is never finished.
+func OpentracingStreamClientInterceptor(t trace.Tracer, opts ...Option) grpc.StreamClientInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		tr := t.StartSpanFromContextOrRoot(ctx, method, ext.SpanKindRPCClient)
+		setMethodTags(&tr, method)
+		tr.SetTag(trace.Tag(tagClientStream, desc.ClientStreams), trace.Tag(tagServerStream, desc.ServerStreams))
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if !ok {
+			md = metadata.New(nil)
+		} else {
+			md = md.Copy()
+		}
+		if err := tr.Inject(opentracing.TextMap, MDReaderWriter{md}); err != nil {
+			tr.SetTag(trace.Tag(trace.TagError, true))
+		}
+		ctx = metadata.NewOutgoingContext(ctx, md)
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			finishWithStatus(&tr, err, o)
+			return nil, err
+		}
+		st := newStreamTracer(tr, o)
+		go func() {
+			select {
+			case <-ctx.Done():
+				st.finish(ctx.Err())
+			case <-st.done:
+			}
+		}()
+		return &tracedClientStream{ClientStream: cs, desc: desc, st: st}, nil
+	}
+}
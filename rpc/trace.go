@@ -36,31 +36,34 @@ func (c MDReaderWriter) ForeachKey(handler func(key, val string) error) error {
 }
 
 // OpentracingServerInterceptor rewrite server's interceptor with open tracing
-func OpentracingServerInterceptor(t trace.Tracer) grpc.UnaryServerInterceptor {
+func OpentracingServerInterceptor(t trace.Tracer, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		md, ok := metadata.FromIncomingContext(ctx)
 		if !ok {
 			md = metadata.New(nil)
 		}
-		tag := trace.Tag(string(trace.TagComponent), "gRPC")
+		var tr trace.Tracer
 		spanCtx, err := t.Extract(opentracing.TextMap, MDReaderWriter{md})
 		if err != nil {
-			t = t.StartSpan(info.FullMethod, tag)
+			tr = t.StartSpan(info.FullMethod, ext.SpanKindRPCServer)
 		} else {
-			t = t.StartSpan(info.FullMethod, ext.RPCServerOption(spanCtx), tag, ext.SpanKindRPCServer)
+			tr = t.StartSpan(info.FullMethod, ext.RPCServerOption(spanCtx))
 		}
-		defer t.Finish(nil)
-		// ctx = trace.ContextWithSpan(ctx, t.GetSpan())
-		ctx = context.WithValue(ctx, trace.CtxKey, t.GetSpan())
-		return handler(ctx, req)
+		setMethodTags(&tr, info.FullMethod)
+		ctx = tr.ContextWithSpan(ctx)
+		resp, err = handler(ctx, req)
+		finishWithStatus(&tr, err, o)
+		return resp, err
 	}
 }
 
 // OpentracingClientInterceptor rewrite client's interceptor with open tracing
-func OpentracingClientInterceptor(t trace.Tracer) grpc.UnaryClientInterceptor {
-	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		tr, _ := t.StartSpanFromContext(ctx, method, ext.SpanKindRPCClient)
-		_ = tr.SetTag(trace.Tag(trace.TagComponent, "gRPC"))
+func OpentracingClientInterceptor(t trace.Tracer, opts ...Option) grpc.UnaryClientInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		tr := t.StartSpanFromContextOrRoot(ctx, method, ext.SpanKindRPCClient)
+		setMethodTags(&tr, method)
 		md, ok := metadata.FromOutgoingContext(ctx)
 		if !ok {
 			md = metadata.New(nil)
@@ -73,11 +76,8 @@ func OpentracingClientInterceptor(t trace.Tracer) grpc.UnaryClientInterceptor {
 			tr.SetTag(trace.Tag(trace.TagError, true))
 		}
 		ctx = metadata.NewOutgoingContext(ctx, md)
-		err = invoker(ctx, method, req, reply, cc, opts...)
-		if err != nil {
-			tr.SetTag(trace.Tag(trace.TagError, err.Error()))
-		}
-		tr.Finish(&err)
+		err = invoker(ctx, method, req, reply, cc, callOpts...)
+		finishWithStatus(&tr, err, o)
 		return err
 	}
 }
@@ -0,0 +1,307 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "go-trace/tests/test"
+	"go-trace/trace"
+	"go-trace/trace/tracetest"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	streamMethod = "/tests.Stream/Chat"
+	uploadMethod = "/tests.Stream/Upload"
+)
+
+type testServer struct{}
+
+// SayHello returns the error code given by name
+func (testServer) SayHello(ctx context.Context, in *pb.HelloRequest) (*pb.HelloResponse, error) {
+	switch in.Name {
+	case "notfound":
+		return nil, status.Error(codes.NotFound, "user not found")
+	case "internal":
+		return nil, status.Error(codes.Internal, "database down")
+	case "nested":
+		if opentracing.SpanFromContext(ctx) == nil {
+			return nil, status.Error(codes.FailedPrecondition, "span not in context")
+		}
+		tr, ok := trace.StartSpanFromContext(ctx, "nested")
+		if !ok {
+			return nil, status.Error(codes.FailedPrecondition, "parent not found")
+		}
+		tr.Finish(nil)
+	}
+	return &pb.HelloResponse{Name: "hello: " + in.Name}, nil
+}
+
+func chatHandler(srv interface{}, stream grpc.ServerStream) error {
+	for {
+		in := new(pb.HelloRequest)
+		if err := stream.RecvMsg(in); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(&pb.HelloResponse{Name: "hello: " + in.Name}); err != nil {
+			return err
+		}
+	}
+}
+
+// uploadHandler receives all requests and returns one response
+func uploadHandler(srv interface{}, stream grpc.ServerStream) error {
+	n := 0
+	for {
+		if err := stream.RecvMsg(new(pb.HelloRequest)); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		n++
+	}
+	return stream.SendMsg(&pb.HelloResponse{Name: strconv.Itoa(n)})
+}
+
+var (
+	chatDesc = grpc.StreamDesc{
+		StreamName:    "Chat",
+		Handler:       chatHandler,
+		ServerStreams: true,
+		ClientStreams: true,
+	}
+	uploadDesc = grpc.StreamDesc{
+		StreamName:    "Upload",
+		Handler:       uploadHandler,
+		ClientStreams: true,
+	}
+)
+
+// dial starts a server with the test services, returns a client connection
+func dial(t *testing.T, rec *tracetest.Recorder) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		WithUnaryServerChain(OpentracingServerInterceptor(rec.Tracer())),
+		WithStreamServerChain(OpentracingStreamServerInterceptor(rec.Tracer(), WithMessageEvents())),
+	)
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "tests.Stream",
+		HandlerType: (*interface{})(nil),
+		Streams:     []grpc.StreamDesc{chatDesc, uploadDesc},
+		Metadata:    "test.proto",
+	}, struct{}{})
+	pb.RegisterTestServer(s, testServer{})
+	go s.Serve(lis)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+		grpc.WithChainUnaryInterceptor(OpentracingClientInterceptor(rec.Tracer())),
+		grpc.WithChainStreamInterceptor(OpentracingStreamClientInterceptor(rec.Tracer(), WithMessageEvents())),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		s.Stop()
+	})
+	return conn
+}
+
+// waitSpans waits for n spans to be finished
+func waitSpans(t *testing.T, rec *tracetest.Recorder, n int) {
+	deadline := time.Now().Add(time.Second)
+	for len(rec.Spans()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("recorded %d spans, want %d", len(rec.Spans()), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	rec := tracetest.NewRecorder()
+	conn := dial(t, rec)
+	tr := rec.Tracer()
+	root := tr.StartSpan("root")
+	ctx := root.ContextWithSpan(context.Background())
+
+	stream, err := conn.NewStream(ctx, &chatDesc, streamMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err = stream.SendMsg(&pb.HelloRequest{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+		if err = stream.RecvMsg(new(pb.HelloResponse)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	if err = stream.RecvMsg(new(pb.HelloResponse)); err != io.EOF {
+		t.Fatalf("want io.EOF, got %v", err)
+	}
+	root.Finish(nil)
+	waitSpans(t, rec, 3)
+
+	spans := rec.FindByOperation(streamMethod)
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d stream spans, want 2", len(spans))
+	}
+	parent, _ := rec.FindOne("root")
+	client, server := spans[0], spans[1]
+	if client.Tag(trace.TagSpanKind) != ext.SpanKindRPCClientEnum {
+		client, server = server, client
+	}
+	tracetest.AssertChildOf(t, client, parent)
+	tracetest.AssertChildOf(t, server, client)
+	for _, span := range spans {
+		tracetest.AssertTag(t, span, trace.TagRPCService, "tests.Stream")
+		tracetest.AssertTag(t, span, trace.TagRPCMethod, "Chat")
+		tracetest.AssertTag(t, span, trace.TagGRPCStatusCode, int(codes.OK))
+		tracetest.AssertTag(t, span, tagStreamSent, 2)
+		tracetest.AssertTag(t, span, tagStreamReceived, 2)
+		if span.Tag(trace.TagError) != nil {
+			t.Fatalf("span %v should not be failed", span.Tags())
+		}
+		if len(tracetest.LogValues(span, "message.size")) != 4 {
+			t.Fatalf("want 4 message events, got %v", span.Logs())
+		}
+	}
+}
+
+// clientSpan returns the finished client span of method, nil if it's not finished
+func clientSpan(rec *tracetest.Recorder, method string) *tracetest.Span {
+	for _, span := range rec.FindByOperation(method) {
+		if span.Tag(trace.TagSpanKind) == ext.SpanKindRPCClientEnum {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestStreamClientCloseSend(t *testing.T) {
+	rec := tracetest.NewRecorder()
+	conn := dial(t, rec)
+	ctx := context.Background()
+
+	// the span of a server stream is finished by io.EOF after CloseSend
+	stream, err := conn.NewStream(ctx, &chatDesc, streamMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.SendMsg(&pb.HelloRequest{Name: "a"})
+	stream.CloseSend()
+	if clientSpan(rec, streamMethod) != nil {
+		t.Fatal("server stream span finished before io.EOF")
+	}
+	for err == nil {
+		err = stream.RecvMsg(new(pb.HelloResponse))
+	}
+	if err != io.EOF {
+		t.Fatalf("want io.EOF, got %v", err)
+	}
+	span := clientSpan(rec, streamMethod)
+	if span == nil {
+		t.Fatal("server stream span not finished on io.EOF")
+	}
+	tracetest.AssertTag(t, span, trace.TagGRPCStatusCode, int(codes.OK))
+	tracetest.AssertTag(t, span, tagStreamReceived, 1)
+
+	// the span of a client stream is finished by CloseSend, the response may be never received
+	upload, err := conn.NewStream(ctx, &uploadDesc, uploadMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload.SendMsg(&pb.HelloRequest{Name: "a"})
+	if err = upload.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	span = clientSpan(rec, uploadMethod)
+	if span == nil {
+		t.Fatal("client stream span not finished on CloseSend")
+	}
+	tracetest.AssertTag(t, span, tagStreamSent, 1)
+	tracetest.AssertTag(t, span, trace.TagGRPCStatusCode, int(codes.OK))
+}
+
+func TestStreamClientCancel(t *testing.T) {
+	rec := tracetest.NewRecorder()
+	conn := dial(t, rec)
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := conn.NewStream(ctx, &chatDesc, streamMethod); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitSpans(t, rec, 2)
+	for _, span := range rec.FindByOperation(streamMethod) {
+		if span.Tag(trace.TagSpanKind) == ext.SpanKindRPCClientEnum {
+			tracetest.AssertTag(t, span, trace.TagGRPCStatusCode, int(codes.Canceled))
+			if span.Tag(trace.TagError) != nil {
+				t.Fatal("canceled stream should not be failed")
+			}
+			return
+		}
+	}
+	t.Fatal("client span not finished on cancel")
+}
+
+func TestUnaryStatus(t *testing.T) {
+	rec := tracetest.NewRecorder()
+	client := pb.NewTestClient(dial(t, rec))
+	cases := []struct {
+		name   string
+		code   codes.Code
+		failed bool
+	}{
+		{"ok", codes.OK, false},
+		{"notfound", codes.NotFound, false},
+		{"internal", codes.Internal, true},
+	}
+	for _, c := range cases {
+		rec.Reset()
+		client.SayHello(context.Background(), &pb.HelloRequest{Name: c.name})
+		waitSpans(t, rec, 2)
+		for _, span := range rec.FindByOperation("/tests.Test/SayHello") {
+			tracetest.AssertTag(t, span, trace.TagGRPCStatusCode, int(c.code))
+			tracetest.AssertTag(t, span, trace.TagRPCMethod, "SayHello")
+			if failed := span.Tag(trace.TagError) == true; failed != c.failed {
+				t.Fatalf("%s: span %v failed=%v, want %v", c.name, span.Tag(trace.TagSpanKind), failed, c.failed)
+			}
+			if c.code != codes.OK && len(tracetest.LogValues(span, "grpc.code")) != 1 {
+				t.Fatalf("%s: status not logged: %v", c.name, span.Logs())
+			}
+		}
+	}
+}
+
+func TestServerContextSpan(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	client := pb.NewTestClient(dial(t, rec))
+	if _, err := client.SayHello(context.Background(), &pb.HelloRequest{Name: "nested"}); err != nil {
+		t.Fatal(err)
+	}
+	waitSpans(t, rec, 3)
+	nested, _ := rec.FindOne("nested")
+	for _, span := range rec.FindByOperation("/tests.Test/SayHello") {
+		if span.Tag(trace.TagSpanKind) == ext.SpanKindRPCServerEnum {
+			tracetest.AssertChildOf(t, nested, span)
+			return
+		}
+	}
+	t.Fatal("server span not found")
+}
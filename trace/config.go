@@ -0,0 +1,160 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uber/jaeger-client-go"
+)
+
+var (
+	// ErrInvalidConfig is returned (wrapped in *ConfigError) when Config validation fails.
+	ErrInvalidConfig = errors.New("trace: invalid config")
+	// ErrAgentUnreachable is returned when the report agent can't be reached,
+	// the tracer falls back to a no-op tracer.
+	ErrAgentUnreachable = errors.New("trace: agent unreachable")
+)
+
+// Config trace config
+type Config struct {
+	ServiceName         string
+	OpenReporter        bool
+	Stdlog              bool
+	ReportHost          string        // host:port -> 127.0.0.1:9941
+	SamplerType         string        //const, probabilistic, rateLimiting, or remote
+	SamplerParam        float64       // 0 or 1
+	FlushInterval       time.Duration // second, default 1
+	DisableClientTrace  bool
+	DisableMetrics      bool                  // disable RED metrics of spans
+	MetricsRegisterer   prometheus.Registerer // registry of span metrics, default prometheus.DefaultRegisterer, see MetricsHandler
+	MaxMetricOperations int                   // distinct operation labels of span metrics, default DefaultMaxMetricOperations
+	Exporter            *ExporterConfig       // nil reports to the jaeger agent at ReportHost
+	Backend             string                // opentracing (jaeger, default) or otel
+	Propagators         []string              // extract in order and inject all: jaeger (default), w3c, b3, b3-single
+	InjectPropagators   []string              // inject only these, default Propagators
+}
+
+// ConfigError describes an invalid Config field.
+type ConfigError struct {
+	Field  string
+	Value  interface{}
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("trace: invalid config %s=%v: %s", e.Field, e.Value, e.Reason)
+}
+
+// Unwrap returns ErrInvalidConfig
+func (e *ConfigError) Unwrap() error {
+	return ErrInvalidConfig
+}
+
+// AgentError describes a report agent which can't be reached.
+type AgentError struct {
+	Addr string
+	Err  error
+}
+
+func (e *AgentError) Error() string {
+	return fmt.Sprintf("trace: agent %s unreachable: %v", e.Addr, e.Err)
+}
+
+// Is match ErrAgentUnreachable
+func (e *AgentError) Is(target error) bool {
+	return target == ErrAgentUnreachable
+}
+
+// Unwrap returns the underlying error
+func (e *AgentError) Unwrap() error {
+	return e.Err
+}
+
+// Validate check every Config field, returns *ConfigError.
+func (c *Config) Validate() error {
+	if c == nil {
+		return &ConfigError{Field: "Config", Value: nil, Reason: "config is nil"}
+	}
+	if strings.TrimSpace(c.ServiceName) == "" {
+		return &ConfigError{Field: "ServiceName", Value: c.ServiceName, Reason: "service name is required"}
+	}
+	if err := validateSampler(c.SamplerType, c.SamplerParam); err != nil {
+		return err
+	}
+	if c.ReportHost != "" {
+		if err := validateHostPort(c.ReportHost); err != nil {
+			return &ConfigError{Field: "ReportHost", Value: c.ReportHost, Reason: err.Error()}
+		}
+	}
+	if c.FlushInterval < 0 {
+		return &ConfigError{Field: "FlushInterval", Value: c.FlushInterval, Reason: "must not be negative"}
+	}
+	switch c.Backend {
+	case BackendOpentracing, "":
+		if c.Exporter != nil && (c.Exporter.Type == ExporterOTLPGRPC || c.Exporter.Type == ExporterOTLPHTTP) {
+			return &ConfigError{Field: "Exporter.Type", Value: c.Exporter.Type, Reason: "otlp exporters require the otel backend"}
+		}
+	case BackendOTel:
+		if t := strings.ToLower(c.SamplerType); t == jaeger.SamplerTypeRateLimiting || t == jaeger.SamplerTypeRemote {
+			return &ConfigError{Field: "SamplerType", Value: c.SamplerType, Reason: "otel backend supports const and probabilistic samplers"}
+		}
+		if c.Exporter != nil && c.Exporter.Type != ExporterOTLPGRPC && c.Exporter.Type != ExporterOTLPHTTP {
+			return &ConfigError{Field: "Exporter.Type", Value: c.Exporter.Type, Reason: "otel backend supports otlp-grpc and otlp-http"}
+		}
+	default:
+		return &ConfigError{Field: "Backend", Value: c.Backend, Reason: "must be opentracing or otel"}
+	}
+	if err := validatePropagators("Propagators", c.Propagators); err != nil {
+		return err
+	}
+	if err := validatePropagators("InjectPropagators", c.InjectPropagators); err != nil {
+		return err
+	}
+	if c.MaxMetricOperations < 0 {
+		return &ConfigError{Field: "MaxMetricOperations", Value: c.MaxMetricOperations, Reason: "must not be negative"}
+	}
+	if c.Exporter != nil {
+		return c.Exporter.validate()
+	}
+	return nil
+}
+
+func validateSampler(typ string, param float64) error {
+	switch strings.ToLower(typ) {
+	case jaeger.SamplerTypeConst:
+		if param != 0 && param != 1 {
+			return &ConfigError{Field: "SamplerParam", Value: param, Reason: "const sampler param must be 0 or 1"}
+		}
+	case jaeger.SamplerTypeProbabilistic, jaeger.SamplerTypeRemote, "":
+		if param < 0 || param > 1 {
+			return &ConfigError{Field: "SamplerParam", Value: param, Reason: "must be in range [0, 1]"}
+		}
+	case jaeger.SamplerTypeRateLimiting:
+		if param < 0 {
+			return &ConfigError{Field: "SamplerParam", Value: param, Reason: "rate limiting param must not be negative"}
+		}
+	default:
+		return &ConfigError{Field: "SamplerType", Value: typ, Reason: "must be const, probabilistic, rateLimiting or remote"}
+	}
+	return nil
+}
+
+func validateHostPort(hostPort string) error {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return errors.New("missing host")
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
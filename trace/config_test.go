@@ -0,0 +1,60 @@
+package trace
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{
+		ServiceName:   "svc",
+		ReportHost:    "127.0.0.1:6831",
+		SamplerType:   "const",
+		SamplerParam:  1,
+		FlushInterval: time.Second,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+	cases := map[string]func(c *Config){
+		"ServiceName":   func(c *Config) { c.ServiceName = "" },
+		"SamplerType":   func(c *Config) { c.SamplerType = "always" },
+		"SamplerParam":  func(c *Config) { c.SamplerParam = 0.5 },
+		"ReportHost":    func(c *Config) { c.ReportHost = "127.0.0.1" },
+		"FlushInterval": func(c *Config) { c.FlushInterval = -time.Second },
+	}
+	for field, fn := range cases {
+		c := valid
+		fn(&c)
+		err := c.Validate()
+		var ce *ConfigError
+		if !errors.As(err, &ce) || ce.Field != field || !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("%s: got %v", field, err)
+		}
+	}
+	c := valid
+	c.SamplerType, c.SamplerParam = "rateLimiting", 100
+	if err := c.Validate(); err != nil {
+		t.Fatalf("rate limiting config: %v", err)
+	}
+}
+
+func TestNewTracerE(t *testing.T) {
+	defer SetGlobalTracer(GetGlobalTracer())
+	if _, _, err := NewTracerE(&Config{SamplerType: "const"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("want ErrInvalidConfig, got %v", err)
+	}
+	tracer, closer, err := NewTracerE(&Config{
+		ServiceName: "svc",
+		ReportHost:  "agent.invalid:6831",
+		SamplerType: "const",
+	})
+	if !errors.Is(err, ErrAgentUnreachable) {
+		t.Fatalf("want ErrAgentUnreachable, got %v", err)
+	}
+	if tracer == nil || closer == nil || GetGlobalTracer() != tracer {
+		t.Fatal("should fall back to noop tracer")
+	}
+	closer.Close()
+}
@@ -0,0 +1,76 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// ErrorClassifier returns true if err is expected and should not mark the span failed.
+// E.g. redis.Nil, gorm.ErrRecordNotFound
+type ErrorClassifier func(err error) bool
+
+var (
+	// RecordStack add stack to the error log when Finish records an error
+	RecordStack = false
+
+	errMu       sync.RWMutex
+	ignored     []error
+	classifiers []ErrorClassifier
+)
+
+// IgnoreErrors registers expected errors, errors.Is is used to match them.
+// The list is process-wide, the cache and orm packages skip redis.Nil and
+// gorm.ErrRecordNotFound on their own spans without registering them.
+func IgnoreErrors(errs ...error) {
+	errMu.Lock()
+	ignored = append(ignored, errs...)
+	errMu.Unlock()
+}
+
+// RegisterErrorClassifier registers a classifier for expected errors.
+func RegisterErrorClassifier(fn ErrorClassifier) {
+	if fn == nil {
+		return
+	}
+	errMu.Lock()
+	classifiers = append(classifiers, fn)
+	errMu.Unlock()
+}
+
+// IsExpectedError report whether err is ignored or matched by a classifier.
+func IsExpectedError(err error) bool {
+	if err == nil {
+		return true
+	}
+	errMu.RLock()
+	defer errMu.RUnlock()
+	for _, e := range ignored {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	for _, fn := range classifiers {
+		if fn(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorFields returns the standard error log fields of err.
+func ErrorFields(err error) []log.Field {
+	fields := []log.Field{
+		LogString(LogEvent, "error"),
+		LogString(LogErrorKind, fmt.Sprintf("%T", err)),
+		LogObject(LogErrorObject, err),
+		LogString(LogMessage, err.Error()),
+	}
+	if RecordStack {
+		fields = append(fields, LogString(LogStack, string(debug.Stack())))
+	}
+	return fields
+}
@@ -0,0 +1,348 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/transport"
+)
+
+// Exporter types
+const (
+	// ExporterJaegerUDP report spans to the jaeger agent over UDP, default exporter
+	ExporterJaegerUDP = "jaeger-udp"
+	// ExporterJaegerHTTP report spans to the jaeger collector, e.g. http://127.0.0.1:14268/api/traces
+	ExporterJaegerHTTP = "jaeger-http"
+	// ExporterZipkin report spans as zipkin v2 json, e.g. http://127.0.0.1:9411/api/v2/spans
+	ExporterZipkin = "zipkin"
+	// ExporterFile write spans as json lines, Endpoint is the file path, "stdout" or "stderr"
+	ExporterFile = "file"
+
+	defaultQueueSize = 100
+	defaultBatchSize = 100
+	defaultTimeout   = 5 * time.Second
+)
+
+// ExporterConfig span exporter config
+type ExporterConfig struct {
+	Type      string            // jaeger-udp, jaeger-http, zipkin, file, otlp-grpc or otlp-http
+	Endpoint  string            // host:port for jaeger-udp (default ReportHost) and otlp, url for http exporters, path for file
+	Headers   map[string]string // http exporters only
+	User      string            // http basic auth
+	Password  string
+	QueueSize int           // spans waiting to be reported, default 100
+	BatchSize int           // spans per request or write, default 100
+	Timeout   time.Duration // http request timeout, default 5s
+	// FlushInterval flush the batch at least once per interval, default Config.FlushInterval
+	FlushInterval time.Duration
+}
+
+func (e *ExporterConfig) validate() error {
+	switch e.Type {
+	case ExporterJaegerUDP, "":
+		if e.Endpoint != "" {
+			if err := validateHostPort(e.Endpoint); err != nil {
+				return &ConfigError{Field: "Exporter.Endpoint", Value: e.Endpoint, Reason: err.Error()}
+			}
+		}
+	case ExporterJaegerHTTP, ExporterZipkin:
+		u, err := url.Parse(e.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &ConfigError{Field: "Exporter.Endpoint", Value: e.Endpoint, Reason: "must be a http(s) url"}
+		}
+	case ExporterFile, ExporterOTLPGRPC, ExporterOTLPHTTP:
+	default:
+		return &ConfigError{Field: "Exporter.Type", Value: e.Type, Reason: "must be jaeger-udp, jaeger-http, zipkin, file, otlp-grpc or otlp-http"}
+	}
+	if e.QueueSize < 0 || e.BatchSize < 0 {
+		return &ConfigError{Field: "Exporter.QueueSize", Value: e.QueueSize, Reason: "queue and batch size must not be negative"}
+	}
+	if e.Timeout < 0 || e.FlushInterval < 0 {
+		return &ConfigError{Field: "Exporter.FlushInterval", Value: e.FlushInterval, Reason: "must not be negative"}
+	}
+	return nil
+}
+
+// newReporter returns reporter of c.Exporter
+func newReporter(c *Config, logger jaeger.Logger) (jaeger.Reporter, error) {
+	e := c.Exporter
+	batchSize, queueSize, timeout := e.BatchSize, e.QueueSize, e.Timeout
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+	if queueSize == 0 {
+		queueSize = defaultQueueSize
+	}
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	flushInterval := e.FlushInterval
+	if flushInterval == 0 {
+		flushInterval = c.FlushInterval
+	}
+	var (
+		sender jaeger.Transport
+		err    error
+	)
+	switch e.Type {
+	case ExporterJaegerHTTP:
+		opts := []transport.HTTPOption{
+			transport.HTTPBatchSize(batchSize),
+			transport.HTTPHeaders(e.Headers),
+			transport.HTTPTimeout(timeout),
+		}
+		if e.User != "" && e.Password != "" {
+			opts = append(opts, transport.HTTPBasicAuth(e.User, e.Password))
+		}
+		sender = transport.NewHTTPTransport(e.Endpoint, opts...)
+	case ExporterZipkin:
+		sender = newBatchTransport(c.ServiceName, batchSize, &zipkinSender{
+			endpoint: e.Endpoint,
+			headers:  e.Headers,
+			user:     e.User,
+			password: e.Password,
+			client:   &http.Client{Timeout: timeout},
+		})
+	case ExporterFile:
+		var w io.WriteCloser
+		if w, err = openFile(e.Endpoint); err != nil {
+			return nil, err
+		}
+		sender = newBatchTransport(c.ServiceName, batchSize, &fileSender{w: w})
+	default:
+		hostPort := e.Endpoint
+		if hostPort == "" {
+			hostPort = c.ReportHost
+		}
+		if hostPort == "" {
+			hostPort = fmt.Sprintf("%s:%d", jaeger.DefaultUDPSpanServerHost, jaeger.DefaultUDPSpanServerPort)
+		}
+		if sender, err = jaeger.NewUDPTransport(hostPort, 0); err != nil {
+			return nil, err
+		}
+	}
+	var reporter jaeger.Reporter = jaeger.NewRemoteReporter(
+		sender,
+		jaeger.ReporterOptions.QueueSize(queueSize),
+		jaeger.ReporterOptions.BufferFlushInterval(flushInterval),
+		jaeger.ReporterOptions.Logger(logger),
+	)
+	if c.OpenReporter {
+		reporter = jaeger.NewCompositeReporter(jaeger.NewLoggingReporter(logger), reporter)
+	}
+	return reporter, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func openFile(path string) (io.WriteCloser, error) {
+	switch path {
+	case "", "stdout":
+		return nopWriteCloser{os.Stdout}, nil
+	case "stderr":
+		return nopWriteCloser{os.Stderr}, nil
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+}
+
+// spanSender sends a batch of spans
+type spanSender interface {
+	send(spans []*zipkinSpan) error
+	io.Closer
+}
+
+// batchTransport is a jaeger.Transport which converts spans to zipkin v2 model
+// and sends them in batches.
+type batchTransport struct {
+	serviceName string
+	batchSize   int
+	spans       []*zipkinSpan
+	sender      spanSender
+}
+
+func newBatchTransport(serviceName string, batchSize int, sender spanSender) *batchTransport {
+	return &batchTransport{
+		serviceName: serviceName,
+		batchSize:   batchSize,
+		sender:      sender,
+	}
+}
+
+// Append implements jaeger.Transport
+func (t *batchTransport) Append(span *jaeger.Span) (int, error) {
+	t.spans = append(t.spans, toZipkinSpan(t.serviceName, span))
+	if len(t.spans) >= t.batchSize {
+		return t.Flush()
+	}
+	return 0, nil
+}
+
+// Flush implements jaeger.Transport
+func (t *batchTransport) Flush() (int, error) {
+	n := len(t.spans)
+	if n == 0 {
+		return 0, nil
+	}
+	err := t.sender.send(t.spans)
+	t.spans = t.spans[:0]
+	return n, err
+}
+
+// Close implements jaeger.Transport
+func (t *batchTransport) Close() error {
+	return t.sender.Close()
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// zipkinSpan zipkin v2 span model
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId,omitempty"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind,omitempty"`
+	Timestamp      int64              `json:"timestamp"`
+	Duration       int64              `json:"duration"`
+	Debug          bool               `json:"debug,omitempty"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint,omitempty"`
+	Annotations    []zipkinAnnotation `json:"annotations,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+}
+
+func toZipkinSpan(serviceName string, span *jaeger.Span) *zipkinSpan {
+	ctx := span.SpanContext()
+	zs := &zipkinSpan{
+		TraceID:       formatTraceID(ctx.TraceID()),
+		ID:            fmt.Sprintf("%016x", uint64(ctx.SpanID())),
+		Name:          span.OperationName(),
+		Timestamp:     span.StartTime().UnixNano() / int64(time.Microsecond),
+		Duration:      int64(span.Duration() / time.Microsecond),
+		Debug:         ctx.IsDebug(),
+		LocalEndpoint: &zipkinEndpoint{ServiceName: serviceName},
+		Tags:          map[string]string{},
+	}
+	if ctx.ParentID() != 0 {
+		zs.ParentID = fmt.Sprintf("%016x", uint64(ctx.ParentID()))
+	}
+	for k, v := range span.Tags() {
+		switch k {
+		case TagSpanKind:
+			zs.Kind = strings.ToUpper(fmt.Sprint(v))
+		case TagPeerService:
+			zs.RemoteEndpoint = &zipkinEndpoint{ServiceName: fmt.Sprint(v)}
+			zs.Tags[k] = fmt.Sprint(v)
+		default:
+			zs.Tags[k] = fmt.Sprint(v)
+		}
+	}
+	for _, record := range span.Logs() {
+		zs.Annotations = append(zs.Annotations, zipkinAnnotation{
+			Timestamp: record.Timestamp.UnixNano() / int64(time.Microsecond),
+			Value:     logValue(record),
+		})
+	}
+	return zs
+}
+
+// logValue returns the event of a single event log, else the json of fields
+func logValue(record opentracing.LogRecord) string {
+	if len(record.Fields) == 1 && record.Fields[0].Key() == LogEvent {
+		return fmt.Sprint(record.Fields[0].Value())
+	}
+	fields := make(map[string]string, len(record.Fields))
+	for _, field := range record.Fields {
+		fields[field.Key()] = fmt.Sprint(field.Value())
+	}
+	data, _ := json.Marshal(fields)
+	return string(data)
+}
+
+func formatTraceID(id jaeger.TraceID) string {
+	if id.High == 0 {
+		return fmt.Sprintf("%016x", id.Low)
+	}
+	return fmt.Sprintf("%016x%016x", id.High, id.Low)
+}
+
+// zipkinSender post spans to zipkin v2 http api
+type zipkinSender struct {
+	endpoint string
+	headers  map[string]string
+	user     string
+	password string
+	client   *http.Client
+}
+
+func (s *zipkinSender) send(spans []*zipkinSpan) error {
+	body, err := json.Marshal(spans)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	if s.user != "" && s.password != "" {
+		req.SetBasicAuth(s.user, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("zipkin: %s returns %s", s.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (s *zipkinSender) Close() error {
+	return nil
+}
+
+// fileSender writes spans as json lines
+type fileSender struct {
+	w io.WriteCloser
+}
+
+func (s *fileSender) send(spans []*zipkinSpan) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, span := range spans {
+		if err := enc.Encode(span); err != nil {
+			return err
+		}
+	}
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+func (s *fileSender) Close() error {
+	return s.w.Close()
+}
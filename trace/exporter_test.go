@@ -0,0 +1,109 @@
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileExporter(t *testing.T) {
+	defer SetGlobalTracer(GetGlobalTracer())
+	dir, err := ioutil.TempDir("", "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.json")
+	_, closer, err := NewTracerE(&Config{
+		ServiceName:  "file-service",
+		SamplerType:  "const",
+		SamplerParam: 1,
+		Exporter:     &ExporterConfig{Type: ExporterFile, Endpoint: path, FlushInterval: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := StartSpan("root", Tag(TagSpanKind, "server"))
+	child := root.Fork("child")
+	child.SetLog(LogString(LogEvent, "GotConn"))
+	child.Finish(nil)
+	root.Finish(nil)
+	closer.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	spans := map[string]zipkinSpan{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span zipkinSpan
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			t.Fatal(err)
+		}
+		spans[span.Name] = span
+	}
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	if spans["child"].ParentID != spans["root"].ID || spans["child"].TraceID != spans["root"].TraceID {
+		t.Fatalf("child %+v not child of root %+v", spans["child"], spans["root"])
+	}
+	if spans["root"].Kind != "SERVER" || spans["root"].LocalEndpoint.ServiceName != "file-service" {
+		t.Fatalf("root span %+v", spans["root"])
+	}
+	if len(spans["child"].Annotations) != 1 || spans["child"].Annotations[0].Value != "GotConn" {
+		t.Fatalf("child annotations %+v", spans["child"].Annotations)
+	}
+}
+
+func TestZipkinExporter(t *testing.T) {
+	defer SetGlobalTracer(GetGlobalTracer())
+	received := make(chan []zipkinSpan, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var spans []zipkinSpan
+		json.NewDecoder(r.Body).Decode(&spans)
+		received <- spans
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	_, closer, err := NewTracerE(&Config{
+		ServiceName:  "zipkin-service",
+		SamplerType:  "const",
+		SamplerParam: 1,
+		Exporter:     &ExporterConfig{Type: ExporterZipkin, Endpoint: srv.URL, BatchSize: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := StartSpan("zipkin")
+	span.Finish(nil)
+	closer.Close()
+	select {
+	case spans := <-received:
+		if len(spans) != 1 || spans[0].Name != "zipkin" {
+			t.Fatalf("received %+v", spans)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("zipkin exporter sent nothing")
+	}
+}
+
+func TestExporterValidate(t *testing.T) {
+	c := &Config{ServiceName: "svc", SamplerType: "const", Exporter: &ExporterConfig{Type: ExporterZipkin, Endpoint: "127.0.0.1:9411"}}
+	if err := c.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("want ErrInvalidConfig, got %v", err)
+	}
+	c.Exporter = &ExporterConfig{Type: "kafka"}
+	if err := c.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("want ErrInvalidConfig, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/uber/jaeger-client-go"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// IDs are the identifiers of a span, e.g. for log lines and response headers.
+// Jaeger and otel ids are fixed width hex as written by w3c and b3 headers.
+type IDs struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// contextWithSpanHook is implemented by the otel bridge tracer, the hook puts the otel span into ctx.
+type contextWithSpanHook interface {
+	ContextWithSpanHook(ctx context.Context, span opentracing.Span) context.Context
+}
+
+// SpanIDs returns IDs of span, ok is false if the span context is unknown.
+func SpanIDs(span opentracing.Span) (ids IDs, ok bool) {
+	if span == nil {
+		return
+	}
+	switch sc := span.Context().(type) {
+	case jaeger.SpanContext:
+		return jaegerIDs(sc)
+	case mocktracer.MockSpanContext:
+		return IDs{TraceID: strconv.Itoa(sc.TraceID), SpanID: strconv.Itoa(sc.SpanID), Sampled: sc.Sampled}, true
+	}
+	// the otel bridge hides the otel span context, its hook puts the otel span into a context
+	if hook, ok := span.Tracer().(contextWithSpanHook); ok {
+		return otelIDs(oteltrace.SpanContextFromContext(hook.ContextWithSpanHook(context.Background(), span)))
+	}
+	return
+}
+
+// IDsFromContext returns IDs of the current span of ctx
+func IDsFromContext(ctx context.Context) (IDs, bool) {
+	return SpanIDs(spanFromContext(ctx))
+}
+
+func jaegerIDs(sc jaeger.SpanContext) (IDs, bool) {
+	if !sc.IsValid() {
+		return IDs{}, false
+	}
+	return IDs{TraceID: formatTraceID(sc.TraceID()), SpanID: fmt.Sprintf("%016x", uint64(sc.SpanID())), Sampled: sc.IsSampled()}, true
+}
+
+func otelIDs(sc oteltrace.SpanContext) (IDs, bool) {
+	if !sc.IsValid() {
+		return IDs{}, false
+	}
+	return IDs{TraceID: sc.TraceID().String(), SpanID: sc.SpanID().String(), Sampled: sc.IsSampled()}, true
+}
@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultMaxMetricOperations is used when Config.MaxMetricOperations is 0
+const DefaultMaxMetricOperations = 500
+
+// OtherOperation is the operation label of spans over the operation limit
+const OtherOperation = "other"
+
+var (
+	// DisableMetrics disables the RED metrics recorded by Tracer.Finish
+	DisableMetrics = false
+	// MaxMetricOperations limits distinct operation labels, spans of new operations over it are labelled OtherOperation
+	MaxMetricOperations = DefaultMaxMetricOperations
+
+	operations     sync.Map
+	operationCount int64
+
+	spanLabels = []string{"operation", "component", "kind"}
+	spansTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "trace_spans_total",
+		Help: "Number of finished spans.",
+	}, spanLabels)
+	spanErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "trace_span_errors_total",
+		Help: "Number of finished spans tagged error=true.",
+	}, spanLabels)
+	spanDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "trace_span_duration_seconds",
+		Help:    "Duration of finished spans.",
+		Buckets: prometheus.DefBuckets,
+	}, spanLabels)
+)
+
+// registerMetrics registers span metrics to reg, default prometheus.DefaultRegisterer.
+// Registering them again to the same registry is not an error.
+func registerMetrics(reg prometheus.Registerer) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	for _, c := range []prometheus.Collector{spansTotal, spanErrors, spanDuration} {
+		if err := reg.Register(c); err != nil {
+			if are, ok := err.(prometheus.AlreadyRegisteredError); ok && are.ExistingCollector == c {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// operationLabel returns name while there are at most MaxMetricOperations distinct operations
+func operationLabel(name string) string {
+	if _, ok := operations.Load(name); ok {
+		return name
+	}
+	if atomic.AddInt64(&operationCount, 1) > int64(MaxMetricOperations) {
+		atomic.AddInt64(&operationCount, -1)
+		return OtherOperation
+	}
+	if _, loaded := operations.LoadOrStore(name, struct{}{}); loaded {
+		atomic.AddInt64(&operationCount, -1)
+	}
+	return name
+}
+
+// MetricsHandler returns the handler of g, nil is the default prometheus registry.
+// Span metrics are registered by NewTracer to Config.MetricsRegisterer,
+// if it's a custom registry pass it as g to serve them.
+func MetricsHandler(g prometheus.Gatherer) http.Handler {
+	if g == nil {
+		return promhttp.Handler()
+	}
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
+
+// started records operation name, start time and the tags of opts, they are used by metrics.
+func (t *Tracer) started(operationName string, opts []opentracing.StartSpanOption) {
+	t.name = operationName
+	t.start = time.Now()
+	var so opentracing.StartSpanOptions
+	for _, opt := range opts {
+		opt.Apply(&so)
+	}
+	for key, value := range so.Tags {
+		t.tags = append(t.tags, opentracing.Tag{Key: key, Value: value})
+	}
+}
+
+// observe records the finished span, spans not started by this Tracer are skipped.
+func (t *Tracer) observe() {
+	if DisableMetrics || t.start.IsZero() {
+		return
+	}
+	var component, kind string
+	failed := false
+	for _, tag := range t.tags {
+		switch tag.Key {
+		case TagComponent:
+			component = fmt.Sprint(tag.Value)
+		case TagSpanKind:
+			kind = fmt.Sprint(tag.Value)
+		case TagError:
+			failed = tag.Value == true
+		}
+	}
+	operation := operationLabel(t.name)
+	spansTotal.WithLabelValues(operation, component, kind).Inc()
+	if failed {
+		spanErrors.WithLabelValues(operation, component, kind).Inc()
+	}
+	spanDuration.WithLabelValues(operation, component, kind).Observe(time.Since(t.start).Seconds())
+}
@@ -0,0 +1,116 @@
+package trace
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		if err := registerMetrics(nil); err != nil {
+			t.Fatalf("register %d: %v", i, err)
+		}
+	}
+	tr := Tracer{Trace: mocktracer.New()}
+	for i := 0; i < 3; i++ {
+		span := tr.StartSpan("metrics:get", ext.SpanKindRPCClient)
+		span.SetTag(Tag(TagComponent, "test"))
+		var err error
+		if i == 0 {
+			err = errors.New("boom")
+		}
+		span.Finish(&err)
+	}
+	renamed := tr.StartSpan("metrics:raw")
+	renamed.SetTitle("metrics:query")
+	renamed.Finish(nil)
+	// spans not started by Tracer are not recorded
+	other := New(mocktracer.New().StartSpan("metrics:other"))
+	other.Finish(nil)
+
+	if n := testutil.ToFloat64(spansTotal.WithLabelValues("metrics:get", "test", "client")); n != 3 {
+		t.Fatalf("spans total %v, want 3", n)
+	}
+	if n := testutil.ToFloat64(spanErrors.WithLabelValues("metrics:get", "test", "client")); n != 1 {
+		t.Fatalf("span errors %v, want 1", n)
+	}
+	if n := testutil.ToFloat64(spansTotal.WithLabelValues("metrics:query", "", "")); n != 1 {
+		t.Fatalf("renamed spans total %v, want 1", n)
+	}
+	if n := testutil.ToFloat64(spansTotal.WithLabelValues("metrics:other", "", "")); n != 0 {
+		t.Fatalf("other spans total %v, want 0", n)
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(nil).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	if !strings.Contains(string(body), `trace_span_duration_seconds_count{component="test",kind="client",operation="metrics:get"} 3`) {
+		t.Fatalf("metrics handler output:\n%s", body)
+	}
+}
+
+func TestMetricsHandlerRegistry(t *testing.T) {
+	defer SetGlobalTracer(GetGlobalTracer())
+	reg := prometheus.NewRegistry()
+	_, closer, err := NewTracerE(&Config{ServiceName: "svc", SamplerType: "const", SamplerParam: 1, MetricsRegisterer: reg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	span := StartSpan("metrics:registry")
+	span.Finish(nil)
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(reg).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	if !strings.Contains(string(body), `trace_spans_total{component="",kind="",operation="metrics:registry"} 1`) {
+		t.Fatalf("custom registry output:\n%s", body)
+	}
+}
+
+func TestRegisterMetricsConflict(t *testing.T) {
+	defer SetGlobalTracer(GetGlobalTracer())
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "trace_spans_total", Help: "host app counter"}))
+	if err := registerMetrics(reg); err == nil {
+		t.Fatal("conflicting collector should fail")
+	}
+	_, _, err := NewTracerE(&Config{ServiceName: "svc", SamplerType: "const", SamplerParam: 1, MetricsRegisterer: reg})
+	if ce, ok := err.(*ConfigError); !ok || ce.Field != "MetricsRegisterer" {
+		t.Fatalf("want MetricsRegisterer config error, got %v", err)
+	}
+	_, closer, err := NewTracerE(&Config{ServiceName: "svc", SamplerType: "const", SamplerParam: 1, MetricsRegisterer: reg, DisableMetrics: true})
+	if err != nil {
+		t.Fatalf("disabled metrics should not register: %v", err)
+	}
+	closer.Close()
+	DisableMetrics = false
+}
+
+func TestMetricsOperationLimit(t *testing.T) {
+	defer func(max int) { MaxMetricOperations = max }(MaxMetricOperations)
+	tr := Tracer{Trace: mocktracer.New()}
+	span := tr.StartSpan("limit:known")
+	span.Finish(nil)
+	MaxMetricOperations = int(atomic.LoadInt64(&operationCount))
+	for _, name := range []string{"limit:/users/1", "limit:/users/2", "limit:known"} {
+		span := tr.StartSpan(name)
+		span.SetTag(Tag(TagComponent, "limit"))
+		span.Finish(nil)
+	}
+	if n := testutil.ToFloat64(spansTotal.WithLabelValues(OtherOperation, "limit", "")); n != 2 {
+		t.Fatalf("other spans total %v, want 2", n)
+	}
+	if n := testutil.ToFloat64(spansTotal.WithLabelValues("limit:known", "limit", "")); n != 1 {
+		t.Fatalf("known spans total %v, want 1", n)
+	}
+}
@@ -0,0 +1,313 @@
+package trace
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	ojaeger "go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel"
+	otbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// Tracer backends
+const (
+	// BackendOpentracing jaeger-client-go backend, default
+	BackendOpentracing = "opentracing"
+	// BackendOTel OpenTelemetry SDK backend, spans are exported over OTLP
+	BackendOTel = "otel"
+)
+
+// OTLP exporter types, only available with BackendOTel
+const (
+	// ExporterOTLPGRPC export spans over OTLP/gRPC, e.g. 127.0.0.1:4317
+	ExporterOTLPGRPC = "otlp-grpc"
+	// ExporterOTLPHTTP export spans over OTLP/HTTP, e.g. http://127.0.0.1:4318/v1/traces
+	ExporterOTLPHTTP = "otlp-http"
+
+	defaultOTLPGRPCEndpoint = "127.0.0.1:4317"
+	defaultOTLPHTTPEndpoint = "127.0.0.1:4318"
+	shutdownTimeout         = 5 * time.Second
+)
+
+// otelTracer is an opentracing.Tracer backed by the OpenTelemetry SDK.
+// the bridge only supports http.Header carriers, otelTracer adapts any
+// TextMap carrier (e.g. gRPC metadata) to it.
+type otelTracer struct {
+	*otbridge.BridgeTracer
+}
+
+// otelSpan returns otelTracer as its tracer, so span.Tracer().Inject
+// supports every carrier.
+type otelSpan struct {
+	opentracing.Span
+	tracer *otelTracer
+}
+
+// Tracer implements opentracing.Span
+func (s otelSpan) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+// StartSpan implements opentracing.Tracer
+func (t *otelTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	return otelSpan{Span: t.BridgeTracer.StartSpan(operationName, opts...), tracer: t}
+}
+
+// ContextWithSpanHook puts the otel span of span into ctx, it's called by opentracing.ContextWithSpan.
+// the bridge only knows its own spans, so otelSpan is unwrapped.
+func (t *otelTracer) ContextWithSpanHook(ctx context.Context, span opentracing.Span) context.Context {
+	if s, ok := span.(otelSpan); ok {
+		span = s.Span
+	}
+	return t.BridgeTracer.ContextWithSpanHook(ctx, span)
+}
+
+// Inject implements opentracing.Tracer
+func (t *otelTracer) Inject(sc opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	if hc, ok := carrier.(opentracing.HTTPHeadersCarrier); ok {
+		return t.BridgeTracer.Inject(sc, opentracing.HTTPHeaders, hc)
+	}
+	if format != opentracing.HTTPHeaders && format != opentracing.TextMap {
+		return opentracing.ErrUnsupportedFormat
+	}
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	header := http.Header{}
+	if err := t.BridgeTracer.Inject(sc, opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)); err != nil {
+		return err
+	}
+	for k, vals := range header {
+		for _, v := range vals {
+			w.Set(k, v)
+		}
+	}
+	return nil
+}
+
+// Extract implements opentracing.Tracer
+func (t *otelTracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	if hc, ok := carrier.(opentracing.HTTPHeadersCarrier); ok {
+		return t.BridgeTracer.Extract(opentracing.HTTPHeaders, hc)
+	}
+	if format != opentracing.HTTPHeaders && format != opentracing.TextMap {
+		return nil, opentracing.ErrUnsupportedFormat
+	}
+	r, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+	header := http.Header{}
+	if err := r.ForeachKey(func(key, val string) error {
+		header.Add(key, val)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return t.BridgeTracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+}
+
+type shutdownCloser struct {
+	provider *sdktrace.TracerProvider
+}
+
+func (c shutdownCloser) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return c.provider.Shutdown(ctx)
+}
+
+// newOTelTracer returns an opentracing.Tracer backed by the OpenTelemetry SDK
+func newOTelTracer(c *Config, logger jaeger.Logger) (opentracing.Tracer, io.Closer, error) {
+	e := c.Exporter
+	if e == nil {
+		e = &ExporterConfig{Type: ExporterOTLPGRPC}
+	}
+	exporter, err := newOTLPExporter(e)
+	if err != nil {
+		return nil, nil, err
+	}
+	batchOpts := []sdktrace.BatchSpanProcessorOption{}
+	if e.QueueSize > 0 {
+		batchOpts = append(batchOpts, sdktrace.WithMaxQueueSize(e.QueueSize))
+	}
+	if e.BatchSize > 0 {
+		batchOpts = append(batchOpts, sdktrace.WithMaxExportBatchSize(e.BatchSize))
+	}
+	if flush := e.FlushInterval; flush > 0 || c.FlushInterval > 0 {
+		if flush == 0 {
+			flush = c.FlushInterval
+		}
+		batchOpts = append(batchOpts, sdktrace.WithBatchTimeout(flush))
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, batchOpts...),
+		sdktrace.WithSampler(otelSampler(c)),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(c.ServiceName),
+		)),
+	)
+	bridge, wrapper := otbridge.NewTracerPair(provider.Tracer(c.ServiceName))
+	bridge.SetWarningHandler(func(msg string) {
+		logger.Infof("otel bridge: %s", msg)
+	})
+	propagator := newOTelPropagator(c)
+	bridge.SetTextMapPropagator(propagator)
+	otel.SetTracerProvider(wrapper)
+	otel.SetTextMapPropagator(propagator)
+	return &otelTracer{BridgeTracer: bridge}, shutdownCloser{provider}, nil
+}
+
+func newOTLPExporter(e *ExporterConfig) (*otlptrace.Exporter, error) {
+	endpoint, insecure, path := otlpEndpoint(e)
+	ctx := context.Background()
+	if e.Type == ExporterOTLPHTTP {
+		if endpoint == "" {
+			endpoint = defaultOTLPHTTPEndpoint
+		}
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+		if insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if path != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(path))
+		}
+		if len(e.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(e.Headers))
+		}
+		if e.Timeout > 0 {
+			opts = append(opts, otlptracehttp.WithTimeout(e.Timeout))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+	if endpoint == "" {
+		endpoint = defaultOTLPGRPCEndpoint
+	}
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	if len(e.Headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(e.Headers))
+	}
+	if e.Timeout > 0 {
+		opts = append(opts, otlptracegrpc.WithTimeout(e.Timeout))
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// otlpEndpoint split Endpoint to host:port, insecure and url path.
+// "host:port" is insecure, "http://host:port/path" is insecure, "https://..." uses TLS.
+func otlpEndpoint(e *ExporterConfig) (endpoint string, insecure bool, path string) {
+	if !strings.Contains(e.Endpoint, "://") {
+		return e.Endpoint, true, ""
+	}
+	u, err := url.Parse(e.Endpoint)
+	if err != nil {
+		return e.Endpoint, true, ""
+	}
+	return u.Host, u.Scheme == "http", u.Path
+}
+
+func otelSampler(c *Config) sdktrace.Sampler {
+	switch strings.ToLower(c.SamplerType) {
+	case jaeger.SamplerTypeConst:
+		if c.SamplerParam == 0 {
+			return sdktrace.NeverSample()
+		}
+		return sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case jaeger.SamplerTypeProbabilistic:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SamplerParam))
+	}
+	return sdktrace.ParentBased(sdktrace.AlwaysSample())
+}
+
+// otelPropagator injects with every injector, extracts with the first
+// extractor that finds a span context.
+type otelPropagator struct {
+	injectors  []propagation.TextMapPropagator
+	extractors []propagation.TextMapPropagator
+}
+
+func newOTelPropagator(c *Config) *otelPropagator {
+	extract, inject := c.Propagators, injectPropagators(c)
+	if len(extract) == 0 {
+		extract = []string{PropagatorW3C}
+	}
+	if len(inject) == 0 {
+		inject = []string{PropagatorW3C}
+	}
+	p := &otelPropagator{}
+	for _, name := range extract {
+		p.extractors = append(p.extractors, otelTextMapPropagator(name))
+	}
+	for _, name := range inject {
+		p.injectors = append(p.injectors, otelTextMapPropagator(name))
+	}
+	// baggage is always propagated
+	p.extractors = append(p.extractors, propagation.Baggage{})
+	p.injectors = append(p.injectors, propagation.Baggage{})
+	return p
+}
+
+func otelTextMapPropagator(name string) propagation.TextMapPropagator {
+	switch name {
+	case PropagatorJaeger:
+		return ojaeger.Jaeger{}
+	case PropagatorB3:
+		return b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader))
+	case PropagatorB3Single:
+		return b3.New(b3.WithInjectEncoding(b3.B3SingleHeader))
+	}
+	return propagation.TraceContext{}
+}
+
+// Inject implements propagation.TextMapPropagator
+func (p *otelPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	for _, injector := range p.injectors {
+		injector.Inject(ctx, carrier)
+	}
+}
+
+// Extract implements propagation.TextMapPropagator
+func (p *otelPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	found := false
+	for _, extractor := range p.extractors {
+		if _, ok := extractor.(propagation.Baggage); ok {
+			ctx = extractor.Extract(ctx, carrier)
+			continue
+		}
+		if found {
+			continue
+		}
+		ctx = extractor.Extract(ctx, carrier)
+		found = oteltrace.SpanContextFromContext(ctx).IsValid()
+	}
+	return ctx
+}
+
+// Fields implements propagation.TextMapPropagator
+func (p *otelPropagator) Fields() []string {
+	fields := []string{}
+	for _, extractor := range p.extractors {
+		fields = append(fields, extractor.Fields()...)
+	}
+	return fields
+}
@@ -0,0 +1,76 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+func TestOTelBackend(t *testing.T) {
+	defer SetGlobalTracer(GetGlobalTracer())
+	received := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.URL.Path
+	}))
+	defer srv.Close()
+	_, closer, err := NewTracerE(&Config{
+		ServiceName:  "otel-service",
+		SamplerType:  "const",
+		SamplerParam: 1,
+		Backend:      BackendOTel,
+		Exporter:     &ExporterConfig{Type: ExporterOTLPHTTP, Endpoint: srv.URL + "/v1/traces"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := StartSpan("root")
+	child := root.Fork("child")
+	carrier := opentracing.TextMapCarrier{}
+	if err = child.Inject(opentracing.TextMap, carrier); err != nil {
+		t.Fatal(err)
+	}
+	if carrier["Traceparent"] == "" {
+		t.Fatalf("traceparent not injected: %v", carrier)
+	}
+	ids, ok := SpanIDs(child.GetSpan())
+	if !ok || !ids.Sampled || carrier["Traceparent"] != "00-"+ids.TraceID+"-"+ids.SpanID+"-01" {
+		t.Fatalf("span ids %+v do not match traceparent %s", ids, carrier["Traceparent"])
+	}
+	if ids, ok = IDsFromContext(child.ContextWithSpan(context.Background())); !ok || carrier["Traceparent"] != "00-"+ids.TraceID+"-"+ids.SpanID+"-01" {
+		t.Fatalf("context ids %+v do not match traceparent %s", ids, carrier["Traceparent"])
+	}
+	if sc := oteltrace.SpanContextFromContext(opentracing.ContextWithSpan(context.Background(), child.GetSpan())); !sc.IsValid() {
+		t.Fatal("otel span not put into context")
+	}
+	if _, err = Extract(opentracing.TextMap, carrier); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	child.Finish(nil)
+	root.Finish(nil)
+	if err = closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case path := <-received:
+		if path != "/v1/traces" {
+			t.Fatalf("exported to %s", path)
+		}
+	default:
+		t.Fatal("spans not exported")
+	}
+}
+
+func TestOTelValidate(t *testing.T) {
+	c := &Config{ServiceName: "svc", Backend: BackendOTel, SamplerType: "ratelimiting"}
+	if err := c.Validate(); err == nil {
+		t.Fatal("otel backend should not accept ratelimiting sampler")
+	}
+	c = &Config{ServiceName: "svc", SamplerType: "const", Exporter: &ExporterConfig{Type: ExporterOTLPGRPC}}
+	if err := c.Validate(); err == nil {
+		t.Fatal("otlp exporter should require otel backend")
+	}
+}
@@ -0,0 +1,304 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+	"github.com/uber/jaeger-client-go/config"
+	"github.com/uber/jaeger-client-go/zipkin"
+)
+
+// Propagators, set Config.Propagators to extract and inject several formats
+const (
+	// PropagatorJaeger uber-trace-id header, default
+	PropagatorJaeger = "jaeger"
+	// PropagatorW3C W3C Trace Context traceparent/tracestate headers
+	PropagatorW3C = "w3c"
+	// PropagatorB3 zipkin B3 multi headers, X-B3-TraceId, X-B3-SpanId...
+	PropagatorB3 = "b3"
+	// PropagatorB3Single zipkin B3 single header, b3: {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}
+	PropagatorB3Single = "b3-single"
+
+	headerTraceParent = "traceparent"
+	headerTraceState  = "tracestate"
+	headerB3          = "b3"
+
+	// flags of jaeger.ContextFromString
+	flagSampled = 1
+	flagDebug   = 2
+)
+
+// traceStateKey keys the incoming W3C tracestate in the extended sampling state,
+// it is shared by the spans of a trace in process and is not propagated as baggage.
+type traceStateKey struct{}
+
+func validatePropagators(field string, names []string) error {
+	for _, name := range names {
+		switch name {
+		case PropagatorJaeger, PropagatorW3C, PropagatorB3, PropagatorB3Single:
+		default:
+			return &ConfigError{Field: field, Value: name, Reason: "must be jaeger, w3c, b3 or b3-single"}
+		}
+	}
+	return nil
+}
+
+// injectPropagators returns propagators used to inject
+func injectPropagators(c *Config) []string {
+	if len(c.InjectPropagators) > 0 {
+		return c.InjectPropagators
+	}
+	return c.Propagators
+}
+
+// propagationOptions register the configured propagators for the HTTPHeaders and TextMap formats
+func propagationOptions(c *Config) []config.Option {
+	if len(c.Propagators) == 0 && len(c.InjectPropagators) == 0 {
+		return nil
+	}
+	extract, inject := c.Propagators, injectPropagators(c)
+	if len(extract) == 0 {
+		extract = []string{PropagatorJaeger}
+	}
+	opts := []config.Option{}
+	for _, format := range []opentracing.BuiltinFormat{opentracing.HTTPHeaders, opentracing.TextMap} {
+		p := &compositePropagator{}
+		for _, name := range extract {
+			p.extractors = append(p.extractors, newPropagator(name, format))
+		}
+		for _, name := range inject {
+			p.injectors = append(p.injectors, newPropagator(name, format))
+		}
+		opts = append(opts, config.Injector(format, p), config.Extractor(format, p))
+	}
+	return opts
+}
+
+type propagator interface {
+	jaeger.Injector
+	jaeger.Extractor
+}
+
+func newPropagator(name string, format opentracing.BuiltinFormat) propagator {
+	switch name {
+	case PropagatorW3C:
+		return w3cPropagator{}
+	case PropagatorB3:
+		return zipkin.NewZipkinB3HTTPHeaderPropagator()
+	case PropagatorB3Single:
+		return b3SinglePropagator{}
+	}
+	headers := (&jaeger.HeadersConfig{}).ApplyDefaults()
+	if format == opentracing.HTTPHeaders {
+		return jaeger.NewHTTPHeaderPropagator(headers, *jaeger.NewNullMetrics())
+	}
+	return jaeger.NewTextMapPropagator(headers, *jaeger.NewNullMetrics())
+}
+
+// compositePropagator injects with every injector, extracts with the first
+// extractor that finds a span context.
+type compositePropagator struct {
+	injectors  []jaeger.Injector
+	extractors []jaeger.Extractor
+}
+
+// Inject implements jaeger.Injector
+func (p *compositePropagator) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	for _, injector := range p.injectors {
+		if err := injector.Inject(sc, carrier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extract implements jaeger.Extractor
+func (p *compositePropagator) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	err := opentracing.ErrSpanContextNotFound
+	for _, extractor := range p.extractors {
+		sc, e := extractor.Extract(carrier)
+		if e == nil && sc.IsValid() {
+			return sc, nil
+		}
+		if e != nil && e != opentracing.ErrSpanContextNotFound {
+			err = e
+		}
+	}
+	return jaeger.SpanContext{}, err
+}
+
+// textMapHeaders read the carrier to a map with lower case keys
+func textMapHeaders(carrier interface{}) (map[string]string, error) {
+	r, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+	headers := map[string]string{}
+	err := r.ForeachKey(func(key, val string) error {
+		headers[strings.ToLower(key)] = val
+		return nil
+	})
+	return headers, err
+}
+
+func formatID(id jaeger.TraceID) string {
+	return fmt.Sprintf("%016x%016x", id.High, id.Low)
+}
+
+func parseTraceID(s string) (jaeger.TraceID, error) {
+	if len(s) != 16 && len(s) != 32 {
+		return jaeger.TraceID{}, opentracing.ErrSpanContextCorrupted
+	}
+	id, err := jaeger.TraceIDFromString(s)
+	if err != nil || !id.IsValid() {
+		return jaeger.TraceID{}, opentracing.ErrSpanContextCorrupted
+	}
+	return id, nil
+}
+
+func parseSpanID(s string) (jaeger.SpanID, error) {
+	if len(s) != 16 {
+		return 0, opentracing.ErrSpanContextCorrupted
+	}
+	id, err := jaeger.SpanIDFromString(s)
+	if err != nil || id == 0 {
+		return 0, opentracing.ErrSpanContextCorrupted
+	}
+	return id, nil
+}
+
+// w3cPropagator W3C Trace Context, https://www.w3.org/TR/trace-context/
+type w3cPropagator struct{}
+
+// Inject implements jaeger.Injector
+func (w3cPropagator) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	flags := "00"
+	if sc.IsSampled() {
+		flags = "01"
+	}
+	w.Set(headerTraceParent, fmt.Sprintf("00-%s-%016x-%s", formatID(sc.TraceID()), uint64(sc.SpanID()), flags))
+	if state := traceState(sc); state != "" {
+		w.Set(headerTraceState, state)
+	}
+	return nil
+}
+
+// Extract implements jaeger.Extractor
+func (w3cPropagator) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	headers, err := textMapHeaders(carrier)
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	value, ok := headers[headerTraceParent]
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	if len(parts[1]) != 32 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	traceID, err := parseTraceID(parts[1])
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	spanID, err := parseSpanID(parts[2])
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || len(parts[3]) != 2 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+	}
+	sc := jaeger.NewSpanContext(traceID, spanID, 0, flags&1 == 1, nil)
+	if state := headers[headerTraceState]; state != "" {
+		sc.ExtendedSamplingState(traceStateKey{}, func() interface{} { return state })
+	}
+	return sc, nil
+}
+
+// traceState returns the tracestate extracted for the trace of sc
+func traceState(sc jaeger.SpanContext) string {
+	if !sc.IsValid() {
+		return ""
+	}
+	state, _ := sc.ExtendedSamplingState(traceStateKey{}, func() interface{} { return "" }).(string)
+	return state
+}
+
+// b3SinglePropagator zipkin B3 single header, https://github.com/openzipkin/b3-propagation
+type b3SinglePropagator struct{}
+
+// Inject implements jaeger.Injector
+func (b3SinglePropagator) Inject(sc jaeger.SpanContext, carrier interface{}) error {
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	sampled := "0"
+	if sc.IsDebug() {
+		sampled = "d"
+	} else if sc.IsSampled() {
+		sampled = "1"
+	}
+	value := fmt.Sprintf("%s-%016x-%s", formatTraceID(sc.TraceID()), uint64(sc.SpanID()), sampled)
+	if sc.ParentID() != 0 {
+		value = fmt.Sprintf("%s-%016x", value, uint64(sc.ParentID()))
+	}
+	w.Set(headerB3, value)
+	return nil
+}
+
+// Extract implements jaeger.Extractor
+func (b3SinglePropagator) Extract(carrier interface{}) (jaeger.SpanContext, error) {
+	headers, err := textMapHeaders(carrier)
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	value, ok := headers[headerB3]
+	if !ok {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	// a single sampling state, e.g. "0", carries no span context
+	if len(parts) < 2 || len(parts) > 4 {
+		return jaeger.SpanContext{}, opentracing.ErrSpanContextNotFound
+	}
+	traceID, err := parseTraceID(parts[0])
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	spanID, err := parseSpanID(parts[1])
+	if err != nil {
+		return jaeger.SpanContext{}, err
+	}
+	var flags byte = flagSampled
+	if len(parts) > 2 {
+		switch parts[2] {
+		case "1":
+		case "d":
+			flags = flagSampled | flagDebug
+		case "0":
+			flags = 0
+		default:
+			return jaeger.SpanContext{}, opentracing.ErrSpanContextCorrupted
+		}
+	}
+	var parentID jaeger.SpanID
+	if len(parts) > 3 {
+		if parentID, err = parseSpanID(parts[3]); err != nil {
+			return jaeger.SpanContext{}, err
+		}
+	}
+	// NewSpanContext can not set the debug flag
+	return jaeger.ContextFromString(fmt.Sprintf("%s:%016x:%016x:%d", formatTraceID(traceID), uint64(spanID), uint64(parentID), flags))
+}
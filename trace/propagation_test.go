@@ -0,0 +1,102 @@
+package trace
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+)
+
+func newPropagationTracer(t *testing.T, extract, inject []string) {
+	_, closer, err := NewTracerE(&Config{
+		ServiceName:       "propagation-service",
+		ReportHost:        "127.0.0.1:6831",
+		SamplerType:       "const",
+		SamplerParam:      1,
+		Propagators:       extract,
+		InjectPropagators: inject,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { closer.Close() })
+}
+
+func TestPropagatorsInject(t *testing.T) {
+	defer SetGlobalTracer(GetGlobalTracer())
+	newPropagationTracer(t, []string{PropagatorW3C, PropagatorB3, PropagatorB3Single, PropagatorJaeger}, nil)
+	root := StartSpan("root")
+	defer root.Finish(nil)
+	header := http.Header{}
+	if err := root.Inject(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"traceparent", "x-b3-traceid", "b3", "uber-trace-id"} {
+		if header.Get(key) == "" {
+			t.Fatalf("header %s not injected: %v", key, header)
+		}
+	}
+}
+
+func TestPropagatorsExtract(t *testing.T) {
+	defer SetGlobalTracer(GetGlobalTracer())
+	newPropagationTracer(t, []string{PropagatorW3C, PropagatorB3Single, PropagatorJaeger}, []string{PropagatorW3C})
+	cases := map[string]http.Header{
+		"w3c": {
+			"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			"Tracestate":  {"congo=t61rcWkgMzE"},
+		},
+		"b3-single": {"B3": {"4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1"}},
+		"jaeger":    {"Uber-Trace-Id": {"4bf92f3577b34da6a3ce929d0e0e4736:00f067aa0ba902b7:0:1"}},
+	}
+	for name, header := range cases {
+		sc, err := Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		jsc := sc.(jaeger.SpanContext)
+		if jsc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || jsc.SpanID() != 0x00f067aa0ba902b7 || !jsc.IsSampled() {
+			t.Fatalf("%s: extracted %v", name, jsc)
+		}
+		server := StartSpan("server", ext.RPCServerOption(sc))
+		out := http.Header{}
+		server.Inject(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(out))
+		server.GetSpan().Context().ForeachBaggageItem(func(k, v string) bool {
+			t.Fatalf("%s: unexpected baggage %s=%s", name, k, v)
+			return false
+		})
+		server.Finish(nil)
+		if out.Get("uber-trace-id") != "" || out.Get("traceparent") == "" {
+			t.Fatalf("%s: inject only w3c, got %v", name, out)
+		}
+		if name == "w3c" && out.Get("tracestate") != "congo=t61rcWkgMzE" {
+			t.Fatalf("tracestate not propagated: %v", out)
+		}
+	}
+	if _, err := Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(http.Header{})); err != opentracing.ErrSpanContextNotFound {
+		t.Fatalf("want ErrSpanContextNotFound, got %v", err)
+	}
+}
+
+func TestB3SingleDebug(t *testing.T) {
+	p := b3SinglePropagator{}
+	sc, err := p.Extract(opentracing.TextMapCarrier{"b3": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-d-00f067aa0ba902b6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.IsDebug() || !sc.IsSampled() || sc.ParentID() != 0x00f067aa0ba902b6 {
+		t.Fatalf("extracted %v", sc)
+	}
+	out := opentracing.TextMapCarrier{}
+	if err = p.Inject(sc, out); err != nil {
+		t.Fatal(err)
+	}
+	if out["b3"] != "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-d-00f067aa0ba902b6" {
+		t.Fatalf("injected %s", out["b3"])
+	}
+	if sc, err = p.Extract(opentracing.TextMapCarrier{"b3": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0"}); err != nil || sc.IsSampled() || sc.IsDebug() {
+		t.Fatalf("extracted %v, %v", sc, err)
+	}
+}
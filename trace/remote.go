@@ -0,0 +1,24 @@
+package trace
+
+import (
+	"context"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// remoteSpan carries a span context without recording, e.g. the extracted
+// parent of a request that is not traced itself.
+type remoteSpan struct {
+	opentracing.Span
+	sc opentracing.SpanContext
+}
+
+func (s remoteSpan) Context() opentracing.SpanContext { return s.sc }
+
+func (s remoteSpan) Tracer() opentracing.Tracer { return _tracer }
+
+// ContextWithSpanContext returns a new `context.Context` that holds sc,
+// spans started from it are children of sc, nothing is recorded for sc itself.
+func ContextWithSpanContext(ctx context.Context, sc opentracing.SpanContext) context.Context {
+	return ContextWithSpan(ctx, remoteSpan{Span: opentracing.NoopTracer{}.StartSpan(""), sc: sc})
+}
@@ -0,0 +1,211 @@
+package trace
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Statement modes of Sanitizer
+const (
+	// StatementFull keeps the statement with all values
+	StatementFull = "full"
+	// StatementKeys keeps redis command names and keys, values are dropped
+	StatementKeys = "keys"
+	// StatementPlaceholders replaces values with "?", it's the default mode
+	StatementPlaceholders = "placeholders"
+)
+
+const (
+	// DefaultMaxStatementLength is used when Sanitizer.MaxLength is 0
+	DefaultMaxStatementLength = 1024
+	// TruncationMarker is appended to truncated statements
+	TruncationMarker = "...(truncated)"
+)
+
+// Sanitizer sanitize and truncate db.statement of redis and SQL spans.
+// The zero value replaces values with placeholders and truncates at DefaultMaxStatementLength.
+type Sanitizer struct {
+	Mode      string // full, keys or placeholders
+	MaxLength int    // max statement length, negative means no limit
+}
+
+// Validate checks the sanitizer mode
+func (s Sanitizer) Validate() error {
+	switch s.Mode {
+	case "", StatementFull, StatementKeys, StatementPlaceholders:
+		return nil
+	}
+	return fmt.Errorf("unknown statement mode %q", s.Mode)
+}
+
+// Truncate cut statement to MaxLength and append TruncationMarker
+func (s Sanitizer) Truncate(stmt string) string {
+	max := s.MaxLength
+	if max == 0 {
+		max = DefaultMaxStatementLength
+	}
+	if max < 0 || len(stmt) <= max {
+		return stmt
+	}
+	// don't split a multi-byte rune
+	for max > 0 && !utf8.RuneStart(stmt[max]) {
+		max--
+	}
+	return stmt[:max] + TruncationMarker
+}
+
+// Redis returns the statement of a redis command, args[0] is the command name.
+// Keys mode keeps the keys only, placeholders mode replaces other arguments with "?".
+func (s Sanitizer) Redis(args []interface{}) string {
+	if len(args) == 0 {
+		return ""
+	}
+	name := strings.ToLower(redisArg(args[0]))
+	var b strings.Builder
+	b.WriteString(name)
+	isKey := redisKeys(name, args)
+	for i := 1; i < len(args); i++ {
+		switch {
+		case s.Mode == StatementFull || isKey(i):
+			b.WriteByte(' ')
+			b.WriteString(redisArg(args[i]))
+		case s.Mode != StatementKeys:
+			b.WriteString(" ?")
+		}
+	}
+	return s.Truncate(b.String())
+}
+
+// SQL returns the statement of a sql query, full mode returns query as it is,
+// other modes replace string, number and hex literals with "?".
+// Double quoted text is an identifier as in ANSI SQL, e.g. postgres and sqlite.
+func (s Sanitizer) SQL(query string) string {
+	if s.Mode != StatementFull {
+		query = sqlPlaceholders(query, false)
+	}
+	return s.Truncate(query)
+}
+
+// MySQL is SQL for mysql queries, double quoted text is a string literal as in mysql's default sql_mode.
+func (s Sanitizer) MySQL(query string) string {
+	if s.Mode != StatementFull {
+		query = sqlPlaceholders(query, true)
+	}
+	return s.Truncate(query)
+}
+
+func redisArg(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(arg)
+}
+
+// redis commands without keys
+var redisNoKeys = map[string]bool{
+	"auth": true, "client": true, "cluster": true, "command": true, "config": true, "dbsize": true,
+	"echo": true, "flushall": true, "flushdb": true, "hello": true, "info": true, "ping": true,
+	"quit": true, "script": true, "select": true, "time": true, "multi": true, "exec": true, "discard": true,
+}
+
+// redis commands whose arguments are all keys
+var redisAllKeys = map[string]bool{
+	"del": true, "exists": true, "mget": true, "pfcount": true, "rename": true, "renamenx": true,
+	"sdiff": true, "sinter": true, "sunion": true, "touch": true, "unlink": true, "watch": true,
+}
+
+// redisKeys returns a func that reports whether args[i] is a key
+func redisKeys(name string, args []interface{}) func(i int) bool {
+	switch {
+	case redisNoKeys[name]:
+		return func(int) bool { return false }
+	case redisAllKeys[name]:
+		return func(int) bool { return true }
+	case name == "mset" || name == "msetnx":
+		return func(i int) bool { return i%2 == 1 }
+	case name == "eval" || name == "evalsha":
+		n := 0
+		if len(args) > 2 {
+			n, _ = strconv.Atoi(redisArg(args[2]))
+		}
+		return func(i int) bool { return i == 2 || (i > 2 && i <= 2+n) }
+	}
+	return func(i int) bool { return i == 1 }
+}
+
+// sqlPlaceholders replaces quoted strings, numbers and hex literals with "?",
+// doubleQuoted replaces "..." too, otherwise it is kept as an identifier.
+func sqlPlaceholders(query string, doubleQuoted bool) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || (c == '"' && doubleQuoted):
+			i = skipQuoted(query, i)
+			b.WriteByte('?')
+		case isLiteralPrefix(c) && i+1 < len(query) && query[i+1] == '\'' && (i == 0 || !isIdent(query[i-1])):
+			// X'1F', B'01', N'text' and E'text' literals
+			i = skipQuoted(query, i+1)
+			b.WriteByte('?')
+		case c == '0' && i+2 < len(query) && (query[i+1] == 'x' || query[i+1] == 'X') && isHex(query[i+2]) && (i == 0 || !isIdent(query[i-1])):
+			i += 2
+			for i+1 < len(query) && isHex(query[i+1]) {
+				i++
+			}
+			b.WriteByte('?')
+		case isDigit(c) && (i == 0 || !isIdent(query[i-1])):
+			for i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// skipQuoted returns the index of the quote closing the one at query[i],
+// doubled quotes and backslashes escape a quote.
+func skipQuoted(query string, i int) int {
+	quote := query[i]
+	for i++; i < len(query); i++ {
+		if query[i] == '\\' {
+			i++
+		} else if query[i] == quote {
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+			} else {
+				break
+			}
+		}
+	}
+	return i
+}
+
+func isLiteralPrefix(c byte) bool {
+	switch c {
+	case 'x', 'X', 'b', 'B', 'n', 'N', 'e', 'E':
+		return true
+	}
+	return false
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c == '$' || c == '`' || c == '"' || c == '.' || isDigit(c) ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
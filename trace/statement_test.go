@@ -0,0 +1,78 @@
+package trace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizerRedis(t *testing.T) {
+	args := []interface{}{"SET", "session:42", "token-secret", "ex", 60}
+	cases := map[string]string{
+		StatementFull:         "set session:42 token-secret ex 60",
+		StatementKeys:         "set session:42",
+		StatementPlaceholders: "set session:42 ? ? ?",
+		"":                    "set session:42 ? ? ?",
+	}
+	for mode, want := range cases {
+		if got := (Sanitizer{Mode: mode}).Redis(args); got != want {
+			t.Fatalf("mode %q: got %q, want %q", mode, got, want)
+		}
+	}
+	s := Sanitizer{Mode: StatementKeys}
+	for _, c := range []struct {
+		args []interface{}
+		want string
+	}{
+		{[]interface{}{"mset", "a", "1", "b", []byte("2")}, "mset a b"},
+		{[]interface{}{"del", "a", "b"}, "del a b"},
+		{[]interface{}{"hset", "user:1", "email", "a@b.c"}, "hset user:1"},
+		{[]interface{}{"eval", "return 1", 1, "k", "v"}, "eval 1 k"},
+		{[]interface{}{"auth", "password"}, "auth"},
+	} {
+		if got := s.Redis(c.args); got != c.want {
+			t.Fatalf("got %q, want %q", got, c.want)
+		}
+	}
+}
+
+func TestSanitizerSQL(t *testing.T) {
+	query := "SELECT * FROM `user_1` WHERE name = 'O''Neil' AND t1.age > 18 AND id = $1 AND note = 'a\\'b' LIMIT 10"
+	want := "SELECT * FROM `user_1` WHERE name = ? AND t1.age > ? AND id = $1 AND note = ? LIMIT ?"
+	if got := (Sanitizer{}).SQL(query); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := (Sanitizer{Mode: StatementFull}).SQL(query); got != query {
+		t.Fatalf("full mode changed query: %q", got)
+	}
+	for _, c := range []struct {
+		query, sql, mysql string
+	}{
+		{`SELECT * FROM "users" WHERE "users"."name" = 'bob'`, `SELECT * FROM "users" WHERE "users"."name" = ?`, `SELECT * FROM ? WHERE ?.? = ?`},
+		{`SELECT * FROM t WHERE name = "O""Neil" OR name = "a\"b"`, `SELECT * FROM t WHERE name = "O""Neil" OR name = "a\"b"`, `SELECT * FROM t WHERE name = ? OR name = ?`},
+		{`SELECT * FROM t WHERE k = 0x1F2e AND b = X'0aFF' AND c = x'00' AND t0x1 = 1`, `SELECT * FROM t WHERE k = ? AND b = ? AND c = ? AND t0x1 = ?`, `SELECT * FROM t WHERE k = ? AND b = ? AND c = ? AND t0x1 = ?`},
+		{`SELECT * FROM t WHERE n = N'name' AND bits = B'0101' AND hex = x`, `SELECT * FROM t WHERE n = ? AND bits = ? AND hex = x`, `SELECT * FROM t WHERE n = ? AND bits = ? AND hex = x`},
+	} {
+		if got := (Sanitizer{}).SQL(c.query); got != c.sql {
+			t.Fatalf("SQL got %q, want %q", got, c.sql)
+		}
+		if got := (Sanitizer{}).MySQL(c.query); got != c.mysql {
+			t.Fatalf("MySQL got %q, want %q", got, c.mysql)
+		}
+	}
+}
+
+func TestSanitizerTruncate(t *testing.T) {
+	long := strings.Repeat("a", DefaultMaxStatementLength+1)
+	if got := (Sanitizer{}).Truncate(long); got != long[:DefaultMaxStatementLength]+TruncationMarker {
+		t.Fatalf("default max length not applied, len %d", len(got))
+	}
+	if got := (Sanitizer{MaxLength: -1}).Truncate(long); got != long {
+		t.Fatal("negative max length should not truncate")
+	}
+	if got := (Sanitizer{MaxLength: 4}).Truncate("ab中文"); got != "ab"+TruncationMarker {
+		t.Fatalf("truncate split rune: %q", got)
+	}
+	if err := (Sanitizer{Mode: "values"}).Validate(); err == nil {
+		t.Fatal("unknown mode should be invalid")
+	}
+}
@@ -119,3 +119,41 @@ const (
 	TagAddress    = "legacy.address"
 	TagComment    = "legacy.comment"
 )
+
+// RPC span tags https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/trace/semantic_conventions/rpc.md
+const (
+	// The value "grpc" for gRPC spans
+	// type string
+	TagRPCSystem = "rpc.system"
+
+	// The full (logical) name of the service being called. E.g., "myservice.EchoService"
+	// type string
+	TagRPCService = "rpc.service"
+
+	// The name of the (logical) method being called. E.g., "exampleMethod"
+	// type string
+	TagRPCMethod = "rpc.method"
+
+	// The numeric status code of the gRPC request. E.g., 0 (OK), 5 (NOT_FOUND)
+	// type integer
+	TagGRPCStatusCode = "rpc.grpc.status_code"
+)
+
+// HTTP server span tags https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/trace/semantic_conventions/http.md
+const (
+	// The matched route template. E.g., "/users/:id"
+	// type string
+	TagHTTPRoute = "http.route"
+
+	// The IP address of the original client behind all proxies. E.g., "83.164.160.102"
+	// type string
+	TagHTTPClientIP = "http.client_ip"
+
+	// Value of the HTTP User-Agent header sent by the client.
+	// type string
+	TagHTTPUserAgent = "http.user_agent"
+
+	// The size of the response body in bytes.
+	// type integer
+	TagHTTPResponseSize = "http.response_content_length"
+)
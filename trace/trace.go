@@ -2,8 +2,10 @@ package trace
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"time"
 
 	"github.com/opentracing/opentracing-go"
@@ -14,26 +16,18 @@ import (
 
 var (
 	// global tracer
-	_tracer opentracing.Tracer
-	maxTags = 128
-	maxLogs = 256
+	_tracer opentracing.Tracer = opentracing.NoopTracer{}
+	maxTags                    = 128
+	maxLogs                    = 256
 	// DisableClientTrace .
 	DisableClientTrace = false
-	// CtxKey gin.Context trace key
+	// CtxKey gin.Context trace key, gin.Context only supports string keys.
+	// Deprecated: use ContextWithSpan, CtxKey is only read as a fallback.
 	CtxKey = "library/net/trace.trace"
 )
 
-// Config trace config
-type Config struct {
-	ServiceName        string
-	OpenReporter       bool
-	Stdlog             bool
-	ReportHost         string        // host:port -> 127.0.0.1:9941
-	SamplerType        string        //const, probabilistic, rateLimiting, or remote
-	SamplerParam       float64       // 0 or 1
-	FlushInterval      time.Duration // second, default 1
-	DisableClientTrace bool
-}
+// ctxKey is the context key of the current span
+type ctxKey struct{}
 
 // SetGlobalTracer set global tracer
 func SetGlobalTracer(tracer opentracing.Tracer) {
@@ -46,8 +40,24 @@ func GetGlobalTracer() opentracing.Tracer {
 	return _tracer
 }
 
-// NewTracer return Tracer
+// NewTracer return Tracer, panic if config is invalid.
+// If the agent can't be reached a no-op tracer is returned.
 func NewTracer(c *Config) (opentracing.Tracer, io.Closer) {
+	tracer, closer, err := NewTracerE(c)
+	if err != nil && !errors.Is(err, ErrAgentUnreachable) {
+		panic(fmt.Sprintf("Init trace error: %v\n", err))
+	}
+	return tracer, closer
+}
+
+// NewTracerE return Tracer and the error of config.
+// If the agent can't be reached, a no-op tracer is set as global tracer
+// and returned with an error matching ErrAgentUnreachable,
+// other errors are returned without a tracer.
+func NewTracerE(c *Config) (opentracing.Tracer, io.Closer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
 	cfg := &config.Configuration{
 		ServiceName: c.ServiceName,
 		Sampler: &config.SamplerConfig{
@@ -62,21 +72,91 @@ func NewTracer(c *Config) (opentracing.Tracer, io.Closer) {
 	}
 	// jaeger.StdLogger
 	opts := []config.Option{}
+	var logger jaeger.Logger = jaeger.NullLogger
 	if c.Stdlog {
-		opts = append(opts, config.Logger(jaeger.StdLogger))
+		logger = jaeger.StdLogger
+		opts = append(opts, config.Logger(logger))
+	}
+	DisableClientTrace = c.DisableClientTrace
+	DisableMetrics = c.DisableMetrics
+	if !c.DisableMetrics {
+		if err := registerMetrics(c.MetricsRegisterer); err != nil {
+			return nil, nil, &ConfigError{Field: "MetricsRegisterer", Value: c.MetricsRegisterer, Reason: err.Error()}
+		}
+		MaxMetricOperations = DefaultMaxMetricOperations
+		if c.MaxMetricOperations > 0 {
+			MaxMetricOperations = c.MaxMetricOperations
+		}
+	}
+	if c.Backend == BackendOTel {
+		tracer, closer, err := newOTelTracer(c, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		SetGlobalTracer(tracer)
+		return tracer, closer, nil
+	}
+	if agent := agentAddr(c); agent != "" {
+		if _, err := net.ResolveUDPAddr("udp", agent); err != nil {
+			return noopTracer(c, &AgentError{Addr: agent, Err: err})
+		}
+	}
+	opts = append(opts, propagationOptions(c)...)
+	if c.Exporter != nil {
+		reporter, err := newReporter(c, logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, config.Reporter(reporter))
+	} else {
+		// the udp transport of the agent is the only error of the reporter,
+		// errors of cfg.NewTracer are returned as they are.
+		reporter, err := cfg.Reporter.NewReporter(c.ServiceName, nil, logger)
+		if err != nil {
+			return noopTracer(c, &AgentError{Addr: agentAddr(c), Err: err})
+		}
+		opts = append(opts, config.Reporter(reporter))
 	}
 	tracer, closer, err := cfg.NewTracer(opts...)
 	if err != nil {
-		panic(fmt.Sprintf("Init trace error: %v\n", err))
+		return nil, nil, err
 	}
 	SetGlobalTracer(tracer)
-	DisableClientTrace = c.DisableClientTrace
-	return tracer, closer
+	return tracer, closer, nil
+}
+
+// agentAddr returns the jaeger agent address if spans are reported over UDP
+func agentAddr(c *Config) string {
+	if c.Exporter == nil {
+		return c.ReportHost
+	}
+	if c.Exporter.Type != ExporterJaegerUDP && c.Exporter.Type != "" {
+		return ""
+	}
+	if c.Exporter.Endpoint != "" {
+		return c.Exporter.Endpoint
+	}
+	return c.ReportHost
 }
 
-// ContextWithSpan returns a new `context.Context`
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+func noopTracer(c *Config, err error) (opentracing.Tracer, io.Closer, error) {
+	if c.Stdlog {
+		jaeger.StdLogger.Error(fmt.Sprintf("%v, fall back to noop tracer", err))
+	}
+	tracer := opentracing.NoopTracer{}
+	SetGlobalTracer(tracer)
+	return tracer, nopCloser{}, err
+}
+
+// ContextWithSpan returns a new `context.Context` that holds span,
+// the span is visible to SpanFromContext and opentracing.SpanFromContext.
 func ContextWithSpan(ctx context.Context, span opentracing.Span) context.Context {
-	return opentracing.ContextWithSpan(ctx, span)
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	return context.WithValue(ctx, ctxKey{}, span)
 }
 
 // Extract returns a Trace instance given `format` and `carrier`.
@@ -89,38 +169,47 @@ func Extract(format interface{}, carrier interface{}) (opentracing.SpanContext,
 // incorporate the given StartSpanOption `opts`.
 func StartSpan(operationName string, opts ...opentracing.StartSpanOption) Tracer {
 	span := _tracer.StartSpan(operationName, opts...)
-	return New(span)
+	tracer := New(span)
+	tracer.started(operationName, opts)
+	return tracer
 }
 
 // StartSpanFromContext if context contains parent, return child span
 func StartSpanFromContext(ctx context.Context, operationName string, opts ...opentracing.StartSpanOption) (Tracer, bool) {
-	parent := opentracing.SpanFromContext(ctx)
 	var tracer Tracer
-	if parent != nil {
-		opts = append(opts, opentracing.ChildOf(parent.Context()))
-	} else {
-		if val, ok := ctx.Value(CtxKey).(opentracing.Span); ok {
-			opts = append(opts, opentracing.ChildOf(val.(opentracing.Span).Context()))
-		} else {
-			return tracer, false
-		}
+	parent := spanFromContext(ctx)
+	if parent == nil {
+		return tracer, false
 	}
+	opts = append(opts, opentracing.ChildOf(parent.Context()))
 	span := _tracer.StartSpan(operationName, opts...)
 	tracer = New(span)
+	tracer.started(operationName, opts)
 	return tracer, true
 }
 
 // StartSpanFromContextV2 if context contains parent, return child span
+// Deprecated: use StartSpanFromContext.
 func StartSpanFromContextV2(ctx context.Context, operationName string, opts ...opentracing.StartSpanOption) (Tracer, bool) {
-	var tracer Tracer
-	if val, ok := ctx.Value(CtxKey).(opentracing.Span); ok {
-		opts = append(opts, opentracing.ChildOf(val.(opentracing.Span).Context()))
-	} else {
-		return tracer, false
+	return StartSpanFromContext(ctx, operationName, opts...)
+}
+
+// spanFromContext returns the current span of ctx, lookup order:
+// ContextWithSpan, opentracing.ContextWithSpan, then the CtxKey string key.
+func spanFromContext(ctx context.Context) opentracing.Span {
+	if ctx == nil {
+		return nil
 	}
-	span := _tracer.StartSpan(operationName, opts...)
-	tracer = New(span)
-	return tracer, true
+	if span, ok := ctx.Value(ctxKey{}).(opentracing.Span); ok {
+		return span
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		return span
+	}
+	if span, ok := ctx.Value(CtxKey).(opentracing.Span); ok {
+		return span
+	}
+	return nil
 }
 
 // Tag return opentracing.tag struct
@@ -134,6 +223,8 @@ type Tracer struct {
 	span    opentracing.Span
 	tags    []opentracing.Tag
 	maxLogs int
+	name    string
+	start   time.Time
 }
 
 // New returns a new Tracer
@@ -158,7 +249,9 @@ func NewWithTrace(trace opentracing.Tracer, span opentracing.Span) Tracer {
 func (t *Tracer) Fork(operationName string, opts ...opentracing.StartSpanOption) Tracer {
 	opts = append(opts, opentracing.ChildOf(t.span.Context()))
 	span := t.Trace.StartSpan(operationName, opts...)
-	return NewWithTrace(t.Trace, span)
+	tracer := NewWithTrace(t.Trace, span)
+	tracer.started(operationName, opts)
+	return tracer
 }
 
 // Extract returns a Trace instance given `format` and `carrier`.
@@ -176,12 +269,14 @@ func (t *Tracer) Inject(format interface{}, carrier interface{}) error {
 // incorporate the given StartSpanOption `opts`.
 func (t *Tracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) Tracer {
 	span := t.Trace.StartSpan(operationName, opts...)
-	return NewWithTrace(t.Trace, span)
+	tracer := NewWithTrace(t.Trace, span)
+	tracer.started(operationName, opts)
+	return tracer
 }
 
-// SpanFromContext .
+// SpanFromContext returns the current span of ctx
 func SpanFromContext(ctx context.Context) (t Tracer, ok bool) {
-	parent := opentracing.SpanFromContext(ctx)
+	parent := spanFromContext(ctx)
 	if parent != nil {
 		t = New(parent)
 		ok = true
@@ -189,32 +284,53 @@ func SpanFromContext(ctx context.Context) (t Tracer, ok bool) {
 	return
 }
 
-// StartSpanFromContext if context contains parent, return child span
+// StartSpanFromContext if context contains parent, return child span,
+// ok is false and nothing is started if not, like the package StartSpanFromContext.
 func (t *Tracer) StartSpanFromContext(ctx context.Context, operationName string, opts ...opentracing.StartSpanOption) (Tracer, bool) {
-	parent := opentracing.SpanFromContext(ctx)
-	if parent != nil {
-		opts = append(opts, opentracing.ChildOf(parent.Context()))
-	} else {
-		if val, ok := ctx.Value(CtxKey).(opentracing.Span); ok {
-			opts = append(opts, opentracing.ChildOf(val.(opentracing.Span).Context()))
-		}
+	parent := spanFromContext(ctx)
+	if parent == nil {
+		return Tracer{}, false
 	}
-	span := t.Trace.StartSpan(operationName, opts...)
-	//  ContextWithSpan(ctx, span)
-	return NewWithTrace(t.Trace, span), true
+	return t.StartSpan(operationName, append(opts, opentracing.ChildOf(parent.Context()))...), true
+}
+
+// StartSpanFromContextOrRoot if context contains parent, return child span,
+// otherwise return a new root span. Use it only where a trace may begin, e.g. rpc clients.
+func (t *Tracer) StartSpanFromContextOrRoot(ctx context.Context, operationName string, opts ...opentracing.StartSpanOption) Tracer {
+	if tracer, ok := t.StartSpanFromContext(ctx, operationName, opts...); ok {
+		return tracer
+	}
+	return t.StartSpan(operationName, opts...)
 }
 
 // ContextWithSpan return span context
 func (t *Tracer) ContextWithSpan(ctx context.Context) context.Context {
-	return opentracing.ContextWithSpan(ctx, t.span)
-
+	return ContextWithSpan(ctx, t.span)
 }
 
 // Finish when trace finish call it.
+// if err is not nil and not an expected error, the span is marked failed.
+// The span is recorded by metrics, see DisableMetrics.
 func (t *Tracer) Finish(err *error) {
-	if t.span != nil {
-		t.span.Finish()
+	if t.span == nil {
+		return
 	}
+	if err != nil && *err != nil {
+		t.SetError(*err)
+	}
+	t.span.Finish()
+	t.observe()
+}
+
+// SetError set error=true and add the standard error log,
+// expected errors (see IgnoreErrors) are skipped.
+func (t *Tracer) SetError(err error) *Tracer {
+	if t.span == nil || IsExpectedError(err) {
+		return t
+	}
+	t.SetTag(Tag(TagError, true))
+	t.SetLog(ErrorFields(err)...)
+	return t
 }
 
 // SetTag Adds a tag to the trace.
@@ -249,6 +365,7 @@ func (t *Tracer) GetSpan() opentracing.Span {
 
 // SetTitle reset trace title
 func (t *Tracer) SetTitle(title string) {
+	t.name = title
 	t.span.SetOperationName(title)
 }
 
@@ -0,0 +1,131 @@
+package trace_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"go-trace/trace"
+	"go-trace/trace/tracetest"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+var errExpected = errors.New("expected")
+
+func TestFinishError(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	trace.IgnoreErrors(errExpected)
+
+	failed := trace.StartSpan("failed")
+	err := errors.New("boom")
+	failed.Finish(&err)
+
+	expected := trace.StartSpan("expected")
+	err = fmt.Errorf("wrap: %w", errExpected)
+	expected.Finish(&err)
+
+	ok := trace.StartSpan("ok")
+	ok.Finish(nil)
+
+	span, _ := rec.FindOne("failed")
+	tracetest.AssertTag(t, span, trace.TagError, true)
+	if msg := tracetest.LogValues(span, trace.LogMessage); len(msg) != 1 || msg[0] != "boom" {
+		t.Fatalf("error message logs %v, want [boom]", msg)
+	}
+	if kind := tracetest.LogValues(span, trace.LogErrorKind); len(kind) != 1 || kind[0] != "*errors.errorString" {
+		t.Fatalf("error kind logs %v", kind)
+	}
+	for _, name := range []string{"expected", "ok"} {
+		span, _ := rec.FindOne(name)
+		if span.Tag(trace.TagError) != nil || len(span.Logs()) != 0 {
+			t.Fatalf("span %s should not record error", name)
+		}
+	}
+}
+
+func TestSpanFromContext(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	root := trace.StartSpan("root")
+	legacy := trace.StartSpan("legacy")
+
+	cases := map[string]context.Context{
+		"trace":       root.ContextWithSpan(context.Background()),
+		"opentracing": opentracing.ContextWithSpan(context.Background(), root.GetSpan()),
+		"ctxkey":      context.WithValue(context.Background(), trace.CtxKey, root.GetSpan()),
+		"override":    root.ContextWithSpan(context.WithValue(context.Background(), trace.CtxKey, legacy.GetSpan())),
+	}
+	for name, ctx := range cases {
+		tr, ok := trace.SpanFromContext(ctx)
+		if !ok || tr.GetSpan() != root.GetSpan() {
+			t.Fatalf("%s: SpanFromContext did not return root span", name)
+		}
+		child, ok := trace.StartSpanFromContext(ctx, name)
+		if !ok {
+			t.Fatalf("%s: StartSpanFromContext should find parent", name)
+		}
+		child.Finish(nil)
+		method, ok := tr.StartSpanFromContext(ctx, name+"-method")
+		if !ok {
+			t.Fatalf("%s: Tracer.StartSpanFromContext should find parent", name)
+		}
+		method.Finish(nil)
+	}
+	if _, ok := trace.StartSpanFromContext(context.Background(), "orphan"); ok {
+		t.Fatal("StartSpanFromContext should not start span without parent")
+	}
+	tracer := rec.Tracer()
+	if _, ok := tracer.StartSpanFromContext(context.Background(), "orphan"); ok {
+		t.Fatal("Tracer.StartSpanFromContext should not start span without parent")
+	}
+	newRoot := tracer.StartSpanFromContextOrRoot(context.Background(), "new-root")
+	newRoot.Finish(nil)
+	if span, ok := rec.FindOne("new-root"); !ok || span.ParentID != 0 {
+		t.Fatal("StartSpanFromContextOrRoot should start a root span without parent")
+	}
+	root.Finish(nil)
+	legacy.Finish(nil)
+
+	parent, _ := rec.FindOne("root")
+	for name := range cases {
+		span, _ := rec.FindOne(name)
+		tracetest.AssertChildOf(t, span, parent)
+		span, _ = rec.FindOne(name + "-method")
+		tracetest.AssertChildOf(t, span, parent)
+	}
+}
+
+func TestIDsFromContext(t *testing.T) {
+	tracer, closer := jaeger.NewTracer("ids", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	span := tracer.StartSpan("jaeger")
+	defer span.Finish()
+	ids, ok := trace.IDsFromContext(trace.ContextWithSpan(context.Background(), span))
+	sc := span.Context().(jaeger.SpanContext)
+	if !ok || len(ids.TraceID) != 16 || len(ids.SpanID) != 16 || !ids.Sampled {
+		t.Fatalf("ids %+v are not fixed width", ids)
+	}
+	if ids.TraceID != fmt.Sprintf("%016x", sc.TraceID().Low) || ids.SpanID != fmt.Sprintf("%016x", uint64(sc.SpanID())) {
+		t.Fatalf("ids %+v, want span context %v", ids, sc)
+	}
+	// leading zeros are kept
+	zero := jaeger.NewSpanContext(jaeger.TraceID{Low: 0xabc}, jaeger.SpanID(0xdef), 0, true, nil)
+	child := tracer.StartSpan("zero", opentracing.ChildOf(zero))
+	defer child.Finish()
+	if ids, _ = trace.SpanIDs(child); ids.TraceID != "0000000000000abc" || len(ids.SpanID) != 16 {
+		t.Fatalf("ids %+v are not zero padded", ids)
+	}
+	wide := jaeger.NewSpanContext(jaeger.TraceID{High: 0x1, Low: 0x2}, jaeger.SpanID(0x3), 0, true, nil)
+	child = tracer.StartSpan("wide", opentracing.ChildOf(wide))
+	defer child.Finish()
+	if ids, _ = trace.SpanIDs(child); ids.TraceID != "00000000000000010000000000000002" {
+		t.Fatalf("128 bit trace id %s is not 32 hex digits", ids.TraceID)
+	}
+	if _, ok = trace.IDsFromContext(context.Background()); ok {
+		t.Fatal("context without span should have no ids")
+	}
+}
@@ -0,0 +1,43 @@
+package tracelog
+
+import (
+	"go-trace/trace"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogrusHook adds span ids to entries logged with a context,
+// e.g. logrus.WithContext(ctx).Info("paid").
+type LogrusHook struct {
+	MirrorErrors bool // log error, fatal and panic entries onto the span
+}
+
+var _ logrus.Hook = (*LogrusHook)(nil)
+
+// NewLogrusHook returns a new LogrusHook
+func NewLogrusHook(mirrorErrors bool) *LogrusHook {
+	return &LogrusHook{MirrorErrors: mirrorErrors}
+}
+
+// Levels returns all levels
+func (h *LogrusHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds trace_id, span_id and sampled to entry
+func (h *LogrusHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	ids, ok := trace.IDsFromContext(entry.Context)
+	if !ok {
+		return nil
+	}
+	entry.Data[FieldTraceID] = ids.TraceID
+	entry.Data[FieldSpanID] = ids.SpanID
+	entry.Data[FieldSampled] = ids.Sampled
+	if h.MirrorErrors && entry.Level <= logrus.ErrorLevel {
+		mirror(entry.Context, entry.Level.String(), entry.Message)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build go1.21
+// +build go1.21
+
+package tracelog
+
+import (
+	"context"
+	"log/slog"
+
+	"go-trace/trace"
+)
+
+// SlogHandler adds span ids to records logged with a context,
+// e.g. slog.New(tracelog.NewSlogHandler(h, false)).InfoContext(ctx, "paid").
+type SlogHandler struct {
+	slog.Handler
+	MirrorErrors bool // log error records onto the span
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// NewSlogHandler returns a new SlogHandler wrapping h
+func NewSlogHandler(h slog.Handler, mirrorErrors bool) *SlogHandler {
+	return &SlogHandler{Handler: h, MirrorErrors: mirrorErrors}
+}
+
+// Handle adds trace_id, span_id and sampled to r
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ids, ok := trace.IDsFromContext(ctx); ok {
+		r.AddAttrs(
+			slog.String(FieldTraceID, ids.TraceID),
+			slog.String(FieldSpanID, ids.SpanID),
+			slog.Bool(FieldSampled, ids.Sampled),
+		)
+		if h.MirrorErrors && r.Level >= slog.LevelError {
+			mirror(ctx, r.Level.String(), r.Message)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SlogHandler{Handler: h.Handler.WithAttrs(attrs), MirrorErrors: h.MirrorErrors}
+}
+
+// WithGroup implements slog.Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	return &SlogHandler{Handler: h.Handler.WithGroup(name), MirrorErrors: h.MirrorErrors}
+}
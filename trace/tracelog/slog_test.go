@@ -0,0 +1,40 @@
+//go:build go1.21
+// +build go1.21
+
+package tracelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go-trace/trace"
+	"go-trace/trace/tracetest"
+)
+
+func TestSlogHandler(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	root := trace.StartSpan("slog")
+	ctx := root.ContextWithSpan(context.Background())
+
+	buf := &bytes.Buffer{}
+	logger := slog.New(NewSlogHandler(slog.NewJSONHandler(buf, nil), true)).With("order", 42)
+	logger.ErrorContext(ctx, "charge failed")
+	root.Finish(nil)
+
+	line := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	ids, _ := trace.IDsFromContext(ctx)
+	if line[FieldTraceID] != ids.TraceID || line[FieldSpanID] != ids.SpanID || line["order"] != float64(42) {
+		t.Fatalf("log line %v, want ids %+v", line, ids)
+	}
+	span, _ := rec.FindOne("slog")
+	if msg := tracetest.LogValues(span, trace.LogMessage); len(msg) != 1 || msg[0] != "charge failed" {
+		t.Fatalf("error not mirrored: %v", span.Logs())
+	}
+}
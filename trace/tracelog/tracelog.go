@@ -0,0 +1,29 @@
+// Package tracelog stamps trace_id, span_id and sampled of the current span
+// on log lines, for logrus, zap and log/slog (go1.21).
+package tracelog
+
+import (
+	"context"
+
+	"go-trace/trace"
+)
+
+// Log fields of span ids
+const (
+	FieldTraceID = "trace_id"
+	FieldSpanID  = "span_id"
+	FieldSampled = "sampled"
+)
+
+// mirror logs an error log line onto the current span of ctx
+func mirror(ctx context.Context, level, msg string) {
+	tr, ok := trace.SpanFromContext(ctx)
+	if !ok {
+		return
+	}
+	tr.SetLog(
+		trace.LogString(trace.LogEvent, "log"),
+		trace.LogString("level", level),
+		trace.LogString(trace.LogMessage, msg),
+	)
+}
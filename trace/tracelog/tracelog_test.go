@@ -0,0 +1,70 @@
+package tracelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go-trace/trace"
+	"go-trace/trace/tracetest"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogrusHook(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	root := trace.StartSpan("logrus")
+	ctx := root.ContextWithSpan(context.Background())
+
+	buf := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(NewLogrusHook(true))
+	logger.WithContext(ctx).Error("payment failed")
+	root.Finish(nil)
+
+	line := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	ids, _ := trace.IDsFromContext(ctx)
+	if line[FieldTraceID] != ids.TraceID || line[FieldSpanID] != ids.SpanID || line[FieldSampled] != true {
+		t.Fatalf("log line %v, want ids %+v", line, ids)
+	}
+	span, _ := rec.FindOne("logrus")
+	if msg := tracetest.LogValues(span, trace.LogMessage); len(msg) != 1 || msg[0] != "payment failed" {
+		t.Fatalf("error not mirrored: %v", span.Logs())
+	}
+}
+
+func TestZap(t *testing.T) {
+	rec := tracetest.NewRecorder().Install()
+	defer rec.Uninstall()
+	root := trace.StartSpan("zap")
+	ctx := root.ContextWithSpan(context.Background())
+
+	core, logs := observer.New(zap.InfoLevel)
+	logger := Zap(ctx, zap.New(core), true)
+	logger.Info("paid")
+	logger.Error("refund failed")
+	Zap(context.Background(), zap.New(core), true).Info("no span")
+	root.Finish(nil)
+
+	ids, _ := trace.IDsFromContext(ctx)
+	entries := logs.All()
+	if got := entries[0].ContextMap()[FieldTraceID]; got != ids.TraceID {
+		t.Fatalf("trace_id %v, want %s", got, ids.TraceID)
+	}
+	if _, ok := entries[2].ContextMap()[FieldTraceID]; ok {
+		t.Fatal("log without span should have no trace_id")
+	}
+	span, _ := rec.FindOne("zap")
+	if msg := tracetest.LogValues(span, trace.LogMessage); len(msg) != 1 || msg[0] != "refund failed" {
+		t.Fatalf("error not mirrored: %v", span.Logs())
+	}
+}
@@ -0,0 +1,42 @@
+package tracelog
+
+import (
+	"context"
+
+	"go-trace/trace"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ZapFields returns span id fields of the current span of ctx
+func ZapFields(ctx context.Context) []zap.Field {
+	ids, ok := trace.IDsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return []zap.Field{
+		zap.String(FieldTraceID, ids.TraceID),
+		zap.String(FieldSpanID, ids.SpanID),
+		zap.Bool(FieldSampled, ids.Sampled),
+	}
+}
+
+// Zap returns logger with span id fields of the current span of ctx,
+// if mirrorErrors is true, error and higher entries are logged onto the span.
+func Zap(ctx context.Context, logger *zap.Logger, mirrorErrors bool) *zap.Logger {
+	fields := ZapFields(ctx)
+	if fields == nil {
+		return logger
+	}
+	logger = logger.With(fields...)
+	if mirrorErrors {
+		logger = logger.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
+			if entry.Level >= zapcore.ErrorLevel {
+				mirror(ctx, entry.Level.String(), entry.Message)
+			}
+			return nil
+		}))
+	}
+	return logger
+}
@@ -0,0 +1,148 @@
+package tracetest
+
+import (
+	"sync"
+	"testing"
+
+	"go-trace/trace"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// Span finished span recorded by Recorder
+type Span = mocktracer.MockSpan
+
+// Recorder is an in-memory tracer, it keeps every finished span with its
+// tags, logs, parent id and timings, no jaeger agent required.
+type Recorder struct {
+	*mocktracer.MockTracer
+	mu       sync.Mutex
+	previous opentracing.Tracer
+}
+
+var _ opentracing.Tracer = (*Recorder)(nil)
+
+// NewRecorder returns a new Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{MockTracer: mocktracer.New()}
+}
+
+// Install set recorder as global tracer, trace.StartSpan will use it.
+func (r *Recorder) Install() *Recorder {
+	r.mu.Lock()
+	r.previous = trace.GetGlobalTracer()
+	r.mu.Unlock()
+	trace.SetGlobalTracer(r)
+	return r
+}
+
+// Uninstall restore the global tracer replaced by Install
+func (r *Recorder) Uninstall() {
+	r.mu.Lock()
+	previous := r.previous
+	r.previous = nil
+	r.mu.Unlock()
+	if previous != nil {
+		trace.SetGlobalTracer(previous)
+	}
+}
+
+// Tracer returns a trace.Tracer base on recorder, Tracer.StartSpan and Tracer.Fork use it.
+func (r *Recorder) Tracer() trace.Tracer {
+	return trace.Tracer{Trace: r}
+}
+
+// Spans returns all finished spans
+func (r *Recorder) Spans() []*Span {
+	return r.FinishedSpans()
+}
+
+// FindByOperation returns finished spans with the given operation name
+func (r *Recorder) FindByOperation(operationName string) []*Span {
+	spans := []*Span{}
+	for _, span := range r.FinishedSpans() {
+		if span.OperationName == operationName {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// FindOne returns the first finished span with the given operation name
+func (r *Recorder) FindOne(operationName string) (*Span, bool) {
+	spans := r.FindByOperation(operationName)
+	if len(spans) == 0 {
+		return nil, false
+	}
+	return spans[0], true
+}
+
+// FindByTag returns finished spans that contain the tag key with value
+func (r *Recorder) FindByTag(key string, value interface{}) []*Span {
+	spans := []*Span{}
+	for _, span := range r.FinishedSpans() {
+		if span.Tag(key) == value {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// Children returns finished spans whose parent is span
+func (r *Recorder) Children(parent *Span) []*Span {
+	spans := []*Span{}
+	for _, span := range r.FinishedSpans() {
+		if IsChildOf(span, parent) {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// LogValues returns all values logged under key, in log order.
+func LogValues(span *Span, key string) []string {
+	values := []string{}
+	for _, record := range span.Logs() {
+		for _, field := range record.Fields {
+			if field.Key == key {
+				values = append(values, field.ValueString)
+			}
+		}
+	}
+	return values
+}
+
+// IsChildOf report whether child is a direct child of parent
+func IsChildOf(child, parent *Span) bool {
+	if child == nil || parent == nil {
+		return false
+	}
+	return child.ParentID == parent.SpanContext.SpanID && child.SpanContext.TraceID == parent.SpanContext.TraceID
+}
+
+// AssertChildOf fails the test if child is not a direct child of parent
+func AssertChildOf(tb testing.TB, child, parent *Span) {
+	tb.Helper()
+	if child == nil || parent == nil {
+		tb.Fatalf("span not found: child=%v parent=%v", child, parent)
+		return
+	}
+	if !IsChildOf(child, parent) {
+		tb.Fatalf("span %q (trace:%d parent:%d) is not child of %q (trace:%d span:%d)",
+			child.OperationName, child.SpanContext.TraceID, child.ParentID,
+			parent.OperationName, parent.SpanContext.TraceID, parent.SpanContext.SpanID)
+	}
+}
+
+// AssertTag fails the test if span tag key not equal to want
+func AssertTag(tb testing.TB, span *Span, key string, want interface{}) {
+	tb.Helper()
+	if span == nil {
+		tb.Fatalf("span not found, want tag %s=%v", key, want)
+		return
+	}
+	if got := span.Tag(key); got != want {
+		tb.Fatalf("span %q tag %s=%v (%T), want %v (%T)", span.OperationName, key, got, got, want, want)
+	}
+}
@@ -0,0 +1,50 @@
+package tracetest
+
+import (
+	"context"
+	"testing"
+
+	"go-trace/trace"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder().Install()
+	defer rec.Uninstall()
+
+	root := trace.StartSpan("root")
+	root.SetTag(trace.Tag(trace.TagComponent, "test"))
+	child := root.Fork("child")
+	child.SetLog(trace.LogString(trace.LogEvent, "fork"))
+	child.Finish(nil)
+
+	ctx := root.ContextWithSpan(context.Background())
+	nested, ok := trace.StartSpanFromContext(ctx, "nested")
+	if !ok {
+		t.Fatal("StartSpanFromContext should find parent span")
+	}
+	nested.Finish(nil)
+	root.Finish(nil)
+
+	if len(rec.Spans()) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(rec.Spans()))
+	}
+	parent, _ := rec.FindOne("root")
+	childSpan, _ := rec.FindOne("child")
+	nestedSpan, _ := rec.FindOne("nested")
+	AssertTag(t, parent, trace.TagComponent, "test")
+	AssertChildOf(t, childSpan, parent)
+	AssertChildOf(t, nestedSpan, parent)
+	if len(rec.Children(parent)) != 2 {
+		t.Fatalf("root has %d children, want 2", len(rec.Children(parent)))
+	}
+	if events := LogValues(childSpan, trace.LogEvent); len(events) != 1 || events[0] != "fork" {
+		t.Fatalf("child logs %v, want [fork]", events)
+	}
+	if childSpan.FinishTime.Before(childSpan.StartTime) {
+		t.Fatal("child finish time before start time")
+	}
+	rec.Reset()
+	if len(rec.Spans()) != 0 {
+		t.Fatal("Reset should drop finished spans")
+	}
+}